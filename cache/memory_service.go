@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value   string
+	expires time.Time
+}
+
+// MemoryCache is a PostCache kept in process memory, used when the service
+// runs without Redis.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	items   map[string]memoryItem
+	expires time.Duration
+}
+
+func NewMemoryCache(exp time.Duration) *MemoryCache {
+	return &MemoryCache{
+		items:   make(map[string]memoryItem),
+		expires: exp,
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || (!item.expires.IsZero() && time.Now().After(item.expires)) {
+		return "", fmt.Errorf("memory: key '%s' not found", key)
+	}
+	return item.value, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key, value string) error {
+	item := memoryItem{value: value}
+	if c.expires > 0 {
+		item.expires = time.Now().Add(c.expires)
+	}
+
+	c.mu.Lock()
+	c.items[key] = item
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.mu.Unlock()
+	return nil
+}
@@ -20,3 +20,9 @@ func NewCache(host string, password string, db int, exp time.Duration) *Cache {
 		PostCache: NewRedisCache(host, password, db, exp),
 	}
 }
+
+func NewInMemoryCache(exp time.Duration) *Cache {
+	return &Cache{
+		PostCache: NewMemoryCache(exp),
+	}
+}
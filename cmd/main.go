@@ -7,22 +7,23 @@ import (
 	"newFeatures/cache"
 	"newFeatures/database"
 	"newFeatures/handler"
+	"newFeatures/migration"
 	"newFeatures/repository"
+	"newFeatures/searchsync"
 	"newFeatures/server"
 	"newFeatures/service"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/gocql/gocql"
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
@@ -32,28 +33,53 @@ func main() {
 	}
 
 	port := os.Getenv("API_SERVER_PORT")
-	currentDB := os.Getenv("CURRENT_DB")
+	currentDBs := parseCurrentDBs(os.Getenv("CURRENT_DB"))
+	if len(currentDBs) == 0 {
+		logrus.Fatalf("CURRENT_DB must list at least one database")
+	}
 	kafkaWriter, kafkaReader, err := initializeKafka()
 	if err != nil {
 		logrus.Fatalf("Failed to initialize Kafka: %v", err)
 	}
-	conn, channel, err := initializeRabbitMQ()
-	if err != nil {
-		logrus.Fatalf("Failed to initialize RabbitMQ: %v", err)
+	var conn *amqp.Connection
+	var channel *amqp.Channel
+	var cache *cache.Cache
+	if onlyEmbeddedDBs(currentDBs) {
+		// The embedded backends run without any external processes.
+		cache = initializeMemoryCache()
+	} else {
+		conn, channel, err = initializeRabbitMQ()
+		if err != nil {
+			logrus.Fatalf("Failed to initialize RabbitMQ: %v", err)
+		}
+		cache = initializeRedis()
 	}
-	cache := initializeRedis()
-	r, dbType, err := getRepository(currentDB)
-	if err != nil {
-		logrus.Fatalf("Error occurred while initializing the repository: %s", err.Error())
+
+	dbTypes := make([]string, 0, len(currentDBs))
+	repos := make(map[string]*repository.Repository, len(currentDBs))
+	for _, currentDB := range currentDBs {
+		r, dbType, err := getRepository(currentDB)
+		if err != nil {
+			logrus.Fatalf("Error occurred while initializing the repository: %s", err.Error())
+		}
+		dbTypes = append(dbTypes, dbType)
+		repos[dbType] = r
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := initializeMigration(repos, reg); err != nil {
+		logrus.Fatalf("Error occurred while initializing the migration mode: %s", err.Error())
 	}
 
-	s, err := service.NewTodoService(dbType, r)
+	stopSearchSync, err := initializeSearchSync(repos)
 	if err != nil {
-		return
+		logrus.Fatalf("Error occurred while initializing the search sync: %s", err.Error())
 	}
-	reg := prometheus.NewRegistry()
+	defer stopSearchSync()
+
+	s := service.NewTodoService(dbTypes, repos)
 	handler := handler.NewHandler(s, cache, reg, kafkaWriter, kafkaReader, conn, channel)
-	routes := handler.InitRoutes(dbType)
+	routes := handler.InitRoutes(dbTypes)
 
 	server := new(server.Server)
 	go func() {
@@ -74,166 +100,175 @@ func main() {
 	}
 }
 
-func getRepository(currentDB string) (*repository.Repository, string, error) {
-	var dbType string
-	var db interface{}
-	var err error
-
-	switch currentDB {
-	case "postgres":
-		dbType = repository.PostgresDB
-		db, err = initializePostgresDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize Postgres database: %s", err.Error())
-		}
-	case "mongo":
-		dbType = repository.MongoDB
-		db, err = initializeMongoDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize MongoDB: %s", err.Error())
-		}
-	case "elasticsearch":
-		dbType = repository.ElasticSearchDB
-		db, err = initializeElasticSearch()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize ElasticSearch: %s", err.Error())
-		}
-	case "cassandra":
-		dbType = repository.CassandraDB
-		db, err = initializeCassandraDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize Cassandra: %s", err.Error())
-		}
-	case "maria":
-		dbType = repository.MariaDB
-		db, err = initializeMariaDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize MariaDB: %s", err.Error())
-		}
-	case "clickhouse":
-		dbType = repository.ClickHouseDB
-		db, err = initializeClickHouseDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize ClickHouseDB: %s", err.Error())
+// parseCurrentDBs splits the comma-separated CURRENT_DB list, dropping blanks and duplicates.
+func parseCurrentDBs(currentDB string) []string {
+	var dbs []string
+	seen := make(map[string]bool)
+	for _, db := range strings.Split(currentDB, ",") {
+		db = strings.TrimSpace(db)
+		if db == "" || seen[db] {
+			continue
 		}
-	case "cockroach":
-		dbType = repository.CockroachDB
-		db, err = initializeCockroachDB()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to initialize CockroachDB: %s", err.Error())
+		seen[db] = true
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+func onlyEmbeddedDBs(currentDBs []string) bool {
+	for _, db := range currentDBs {
+		if db != repository.MemoryDB && db != repository.SQLiteDB {
+			return false
 		}
-	default:
-		return nil, "", fmt.Errorf("unsupported database type: %s", currentDB)
 	}
-	repo, err := repository.NewRepository(dbType, db)
+	return true
+}
+
+func getRepository(currentDB string) (*repository.Repository, string, error) {
+	db, err := database.Open(currentDB)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := migrateOnStart(currentDB, db); err != nil {
+		return nil, "", err
+	}
+	repo, err := repository.NewRepository(currentDB, db)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to initialize repository: %s", err.Error())
 	}
-	return repo, dbType, nil
+	return repo, currentDB, nil
 }
 
-func initializePostgresDB() (*sql.DB, error) {
-	if os.Getenv("POSTGRES_HOST") == "" || os.Getenv("POSTGRES_PORT") == "" || os.Getenv("POSTGRES_USER") == "" || os.Getenv("POSTGRES_PASSWORD") == "" || os.Getenv("POSTGRES_DB") == "" || os.Getenv("POSTGRES_SSL_MODE") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+// migrateOnStart applies the pending schema migrations of dbType unless
+// MIGRATE_ON_START is false, in which case they are left to cmd/migrate.
+func migrateOnStart(dbType string, db interface{}) error {
+	if !migration.Supported(dbType) {
+		return nil
 	}
-
-	return database.NewPostgresDB(database.PostgresDB{
-		Host:     os.Getenv("POSTGRES_HOST"),
-		Port:     os.Getenv("POSTGRES_PORT"),
-		Username: os.Getenv("POSTGRES_USER"),
-		Password: os.Getenv("POSTGRES_PASSWORD"),
-		DBName:   os.Getenv("POSTGRES_DB"),
-		SSLMode:  os.Getenv("POSTGRES_SSL_MODE"),
-	})
+	if enabled, err := strconv.ParseBool(os.Getenv("MIGRATE_ON_START")); err == nil && !enabled {
+		return nil
+	}
+	migrator, err := migration.New(dbType, db)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		logrus.Infof("Applied %s migration %04d_%s", dbType, m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %s", dbType, err.Error())
+	}
+	return nil
 }
 
-func initializeMongoDB() (*mongo.Client, error) {
-	if os.Getenv("MONGO_HOST") == "" || os.Getenv("MONGO_PORT") == "" || os.Getenv("MONGO_USERNAME") == "" || os.Getenv("MONGO_PASSWORD") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+// initializeMigration wraps the todo store of MIGRATION_PRIMARY_DB, and its
+// lists and bulk writes where it has them, so that its writes are mirrored to
+// MIGRATION_SECONDARY_DB. It is a no-op unless both are set.
+func initializeMigration(repos map[string]*repository.Repository, reg prometheus.Registerer) error {
+	primary := os.Getenv("MIGRATION_PRIMARY_DB")
+	secondary := os.Getenv("MIGRATION_SECONDARY_DB")
+	if primary == "" && secondary == "" {
+		return nil
+	}
+	if primary == "" || secondary == "" {
+		return fmt.Errorf("both MIGRATION_PRIMARY_DB and MIGRATION_SECONDARY_DB must be set")
+	}
+	if primary == secondary {
+		return fmt.Errorf("migration primary and secondary must differ")
+	}
+	primaryRepo, ok := repos[primary]
+	if !ok {
+		return fmt.Errorf("migration primary %s is not listed in CURRENT_DB", primary)
 	}
 
-	return database.ConnectToMongo(database.MongoDB{
-		Host:     os.Getenv("MONGO_HOST"),
-		Port:     os.Getenv("MONGO_PORT"),
-		Username: os.Getenv("MONGO_USERNAME"),
-		Password: os.Getenv("MONGO_PASSWORD"),
-	})
-}
+	// A secondary the server uses anyway is shared, so that the memory
+	// backend mirrors into the store it serves.
+	secondaryRepo, ok := repos[secondary]
+	if !ok {
+		var err error
+		if secondaryRepo, _, err = getRepository(secondary); err != nil {
+			return err
+		}
+	}
+	shadowReads, _ := strconv.ParseBool(os.Getenv("MIGRATION_SHADOW_READS"))
+	// The default is the id map cmd/transfer writes, so that the todos it
+	// copied are mirrored too.
+	idMapPath := os.Getenv("MIGRATION_ID_MAP")
+	if idMapPath == "" {
+		idMapPath = "transfer_ids.jsonl"
+	}
+	ids, err := repository.OpenMigrationIDMap(idMapPath)
+	if err != nil {
+		return fmt.Errorf("failed to open the migration id map: %w", err)
+	}
 
-func initializeRedis() *cache.Cache {
-	return cache.NewCache(
-		os.Getenv("REDIS_HOST"),
-		os.Getenv("REDIS_PASSWORD"),
-		0,
-		10*time.Minute,
+	todos := repository.NewTodoMigration(
+		primaryRepo.TodoStore,
+		secondaryRepo.TodoStore,
+		primary,
+		secondary,
+		shadowReads,
+		repository.NewMigrationMetrics(reg),
+		ids,
 	)
-}
-func initializeElasticSearch() (*elasticsearch.Client, error) {
-	if os.Getenv("ELASTIC_HOST") == "" || os.Getenv("ELASTIC_USERNAME") == "" || os.Getenv("ELASTIC_PASSWORD") == "" || os.Getenv("ELASTIC_INDEX") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+	primaryRepo.TodoStore = todos
+	if primaryRepo.TodoLists != nil {
+		primaryRepo.TodoLists = repository.NewTodoMigrationLists(primaryRepo.TodoLists, todos)
 	}
-
-	return database.NewElasticSearchDB(database.ElasticSearchDB{
-		Host:     os.Getenv("ELASTIC_HOST"),
-		Username: os.Getenv("ELASTIC_USERNAME"),
-		Password: os.Getenv("ELASTIC_PASSWORD"),
-		Index:    os.Getenv("ELASTIC_INDEX"),
-	})
-}
-
-func initializeCassandraDB() (*gocql.Session, error) {
-	if os.Getenv("CASSANDRA_HOST") == "" || os.Getenv("CASSANDRA_KEYSPACE") == "" || os.Getenv("CASSANDRA_USERNAME") == "" || os.Getenv("CASSANDRA_PASSWORD") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+	if primaryRepo.TodoBulk != nil {
+		primaryRepo.TodoBulk = repository.NewTodoMigrationBulk(primaryRepo.TodoBulk, todos)
 	}
-
-	return database.ConnectToCassandra(database.CassandraDB{
-		Host:     os.Getenv("CASSANDRA_HOST"),
-		Keyspace: os.Getenv("CASSANDRA_KEYSPACE"),
-		Username: os.Getenv("CASSANDRA_USERNAME"),
-		Password: os.Getenv("CASSANDRA_PASSWORD"),
-	})
+	logrus.Infof("Migration mode: writes to %s are mirrored to %s (shadow reads: %t)", primary, secondary, shadowReads)
+	return nil
 }
 
-func initializeMariaDB() (*sql.DB, error) {
-	if os.Getenv("MARIA_USER") == "" || os.Getenv("MARIA_PASSWORD") == "" || os.Getenv("MARIA_HOST") == "" || os.Getenv("MARIA_PORT") == "" || os.Getenv("MARIA_DB") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+// initializeSearchSync mirrors the Postgres todos into Elasticsearch while the
+// server runs, if ELASTIC_SYNC_POSTGRES is true. Both backends must be listed
+// in CURRENT_DB. The returned function stops the sync.
+func initializeSearchSync(repos map[string]*repository.Repository) (func(), error) {
+	if enabled, _ := strconv.ParseBool(os.Getenv("ELASTIC_SYNC_POSTGRES")); !enabled {
+		return func() {}, nil
 	}
-
-	return database.NewMariaDB(database.MariaDB{
-		Host:     os.Getenv("MARIA_HOST"),
-		Port:     os.Getenv("MARIA_PORT"),
-		Username: os.Getenv("MARIA_USER"),
-		Password: os.Getenv("MARIA_PASSWORD"),
-		DBName:   os.Getenv("MARIA_DB"),
-	})
-}
-
-func initializeClickHouseDB() (*sql.DB, error) {
-	if os.Getenv("CLICKHOUSE_HOST") == "" || os.Getenv("CLICKHOUSE_PORT") == "" || os.Getenv("CLICKHOUSE_USER") == "" || os.Getenv("CLICKHOUSE_PASSWORD") == "" || os.Getenv("CLICKHOUSE_DB") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
+	index, ok := repos[repository.ElasticSearchDB]
+	if !ok || repos[repository.PostgresDB] == nil {
+		return nil, fmt.Errorf("the search sync needs both postgres and elasticsearch in CURRENT_DB")
+	}
+	postgres, err := database.Open(repository.PostgresDB)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := database.PostgresListenerFromEnv(repository.TodoChangesChannel)
+	if err != nil {
+		return nil, err
 	}
 
-	return database.NewClickHouseDB(database.ClickHouseDB{
-		Host:     os.Getenv("CLICKHOUSE_HOST"),
-		Port:     os.Getenv("CLICKHOUSE_PORT"),
-		Username: os.Getenv("CLICKHOUSE_USER"),
-		Password: os.Getenv("CLICKHOUSE_PASSWORD"),
-		DBName:   os.Getenv("CLICKHOUSE_DB"),
-	})
+	syncer := searchsync.NewSyncer(repository.NewTodoChanges(postgres.(*sql.DB)), index, 500)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		syncer.Run(ctx, listener, 30*time.Second)
+		close(done)
+	}()
+	logrus.Infof("Search sync: mirroring %s todos into %s", repository.PostgresDB, repository.ElasticSearchDB)
+	return func() {
+		cancel()
+		<-done
+		listener.Close()
+	}, nil
 }
 
-func initializeCockroachDB() (*sql.DB, error) {
-	if os.Getenv("COCKROACH_HOST") == "" || os.Getenv("COCKROACH_PORT") == "" || os.Getenv("COCKROACH_USERNAME") == "" || os.Getenv("COCKROACH_PASSWORD") == "" || os.Getenv("COCKROACH_DB") == "" {
-		return nil, fmt.Errorf("some of the required environment variables are not set")
-	}
+func initializeRedis() *cache.Cache {
+	return cache.NewCache(
+		os.Getenv("REDIS_HOST"),
+		os.Getenv("REDIS_PASSWORD"),
+		0,
+		10*time.Minute,
+	)
+}
 
-	return database.NewCockroachDB(database.CockroachDB{
-		Host:     os.Getenv("COCKROACH_HOST"),
-		Port:     os.Getenv("COCKROACH_PORT"),
-		Username: os.Getenv("COCKROACH_USERNAME"),
-		Password: os.Getenv("COCKROACH_PASSWORD"),
-		DBName:   os.Getenv("COCKROACH_DB"),
-	})
+func initializeMemoryCache() *cache.Cache {
+	return cache.NewInMemoryCache(10 * time.Minute)
 }
 
 func initializeKafka() (*kafka.Writer, *kafka.Reader, error) {
@@ -0,0 +1,175 @@
+// Command migrate applies, reverts and lists the schema migrations of the
+// configured backends.
+//
+//	go run ./cmd/migrate up
+//	go run ./cmd/migrate -db postgres down 2
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate -db elasticsearch reindex
+//	go run ./cmd/migrate -db cassandra backfill
+//
+// Without -db every backend in CURRENT_DB that has migrations is used.
+// Elasticsearch has no numbered migrations; reindex copies its todo index
+// into a new one with the current mapping and swaps the alias over.
+// backfill copies the Cassandra todos written before the listing table
+// existed into it, which CQL cannot do in a migration; it is needed once.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"newFeatures/database"
+	"newFeatures/migration"
+	"newFeatures/repository"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gocql/gocql"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	dbs := flag.String("db", "", "comma-separated backends to migrate, CURRENT_DB by default")
+	envFile := flag.String("env", ".env", "file with the connection settings")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-db backends] [-env file] up | down [steps] | status | reindex | backfill\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := godotenv.Load(*envFile); err != nil {
+		logrus.Fatalf("Error loading %s file. %s", *envFile, err.Error())
+	}
+	if *dbs == "" {
+		*dbs = os.Getenv("CURRENT_DB")
+	}
+
+	command := flag.Arg(0)
+	steps := 1
+	switch command {
+	case "up", "status", "reindex", "backfill":
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+	case "down":
+		if flag.NArg() > 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if flag.NArg() == 2 {
+			n, err := strconv.Atoi(flag.Arg(1))
+			if err != nil || n < 1 {
+				logrus.Fatalf("down steps must be a positive number, got %q", flag.Arg(1))
+			}
+			steps = n
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	for _, dbType := range strings.Split(*dbs, ",") {
+		dbType = strings.TrimSpace(dbType)
+		if dbType == "" {
+			continue
+		}
+		if command == "reindex" {
+			if dbType != repository.ElasticSearchDB {
+				continue
+			}
+			if err := reindex(ctx); err != nil {
+				logrus.Fatalf("%s: %s", dbType, err.Error())
+			}
+			continue
+		}
+		if command == "backfill" {
+			if dbType != repository.CassandraDB {
+				continue
+			}
+			if err := backfill(ctx); err != nil {
+				logrus.Fatalf("%s: %s", dbType, err.Error())
+			}
+			continue
+		}
+		if !migration.Supported(dbType) {
+			logrus.Infof("%s has no schema migrations, skipping", dbType)
+			continue
+		}
+		if err := run(ctx, dbType, command, steps); err != nil {
+			logrus.Fatalf("%s: %s", dbType, err.Error())
+		}
+	}
+}
+
+func reindex(ctx context.Context) error {
+	db, err := database.Open(repository.ElasticSearchDB)
+	if err != nil {
+		return err
+	}
+	index, err := database.ReindexElastic(ctx, db.(*elasticsearch.Client), os.Getenv("ELASTIC_INDEX"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: alias %s now points at %s\n", repository.ElasticSearchDB, os.Getenv("ELASTIC_INDEX"), index)
+	return nil
+}
+
+func backfill(ctx context.Context) error {
+	db, err := database.Open(repository.CassandraDB)
+	if err != nil {
+		return err
+	}
+	moved, err := repository.NewTodoCassandraDB(db.(*gocql.Session)).BackfillListing(ctx)
+	fmt.Printf("%s: listed %d todos written before the listing table\n", repository.CassandraDB, moved)
+	return err
+}
+
+func run(ctx context.Context, dbType, command string, steps int) error {
+	db, err := database.Open(dbType)
+	if err != nil {
+		return err
+	}
+	migrator, err := migration.New(dbType, db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("%s: applied %04d_%s\n", dbType, m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Printf("%s: up to date\n", dbType)
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("%s: reverted %04d_%s\n", dbType, m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Printf("%s: nothing to revert\n", dbType)
+		}
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s: %04d_%s %s\n", dbType, s.Version, s.Name, state)
+		}
+		return nil
+	}
+}
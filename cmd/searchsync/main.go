@@ -0,0 +1,110 @@
+// Command searchsync mirrors the Postgres todos into the Elasticsearch index.
+//
+//	go run ./cmd/searchsync run
+//	go run ./cmd/searchsync -prune resync
+//	go run ./cmd/searchsync stop
+//
+// run applies the changes logged by the todos trigger as they happen, until
+// it is interrupted; the API server does the same when ELASTIC_SYNC_POSTGRES
+// is true. Either installs the trigger when it is missing and then resyncs.
+// resync copies the whole table, which is needed whenever the index was
+// rebuilt. stop removes the trigger and the logged changes once the sync is
+// turned off for good, so that writes to todos no longer pay for it. All
+// read the connection settings of postgres and elasticsearch from -env; the
+// Postgres schema must be migrated to include the change log.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"newFeatures/database"
+	"newFeatures/repository"
+	"newFeatures/searchsync"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	batch := flag.Int64("batch", 500, "number of todos written to the index per bulk request")
+	poll := flag.Duration("poll", 30*time.Second, "interval of the sync rounds that run without a notification")
+	prune := flag.Bool("prune", false, "on resync, also delete documents whose todo is not in Postgres")
+	envFile := flag.String("env", ".env", "file with the connection settings")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] run | resync | stop\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := godotenv.Load(*envFile); err != nil {
+		logrus.Fatalf("Error loading %s file. %s", *envFile, err.Error())
+	}
+	if flag.NArg() != 1 || (flag.Arg(0) != "run" && flag.Arg(0) != "resync" && flag.Arg(0) != "stop") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *batch < 1 {
+		logrus.Fatalf("-batch must be positive")
+	}
+
+	if flag.Arg(0) == "stop" {
+		// Stopping needs Postgres only.
+		postgres, err := database.Open(repository.PostgresDB)
+		if err != nil {
+			logrus.Fatalf("Error occurred while connecting to postgres: %s", err.Error())
+		}
+		if err := repository.NewTodoChanges(postgres.(*sql.DB)).StopCapture(context.Background()); err != nil {
+			logrus.Fatalf("Error occurred while removing the change log trigger: %s", err.Error())
+		}
+		return
+	}
+
+	syncer, err := newSyncer(*batch)
+	if err != nil {
+		logrus.Fatalf("Error occurred while initializing the sync: %s", err.Error())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if flag.Arg(0) == "resync" {
+		stats, err := syncer.Resync(ctx, *prune)
+		fmt.Printf("indexed %d, pruned %d, failed %d\n", stats.Indexed, stats.Pruned, stats.Failed)
+		if err != nil {
+			logrus.Fatalf("Resync stopped: %s", err.Error())
+		}
+		if stats.Failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	listener, err := database.PostgresListenerFromEnv(repository.TodoChangesChannel)
+	if err != nil {
+		logrus.Fatalf("Error occurred while listening for todo changes: %s", err.Error())
+	}
+	defer listener.Close()
+	syncer.Run(ctx, listener, *poll)
+}
+
+func newSyncer(batch int64) (*searchsync.Syncer, error) {
+	postgres, err := database.Open(repository.PostgresDB)
+	if err != nil {
+		return nil, err
+	}
+	elastic, err := database.Open(repository.ElasticSearchDB)
+	if err != nil {
+		return nil, err
+	}
+	index, err := repository.NewRepository(repository.ElasticSearchDB, elastic)
+	if err != nil {
+		return nil, err
+	}
+	return searchsync.NewSyncer(repository.NewTodoChanges(postgres.(*sql.DB)), index, batch), nil
+}
@@ -0,0 +1,384 @@
+// Command transfer copies every todo from one configured backend into another.
+//
+// Todos are read page by page from the source, by id, and created one by one
+// on the target, which assigns its own ids. Every copied todo is appended to
+// the id map file as a {"source","target"} pair, and the position after the
+// last todo read is stored in the checkpoint file after each batch, so an
+// interrupted run picks up where it stopped without creating duplicates.
+// Todos keep their owner, fields and timestamps; see copyTodo for lists and
+// subtasks. Both files are plain JSON and are kept after the run so the id
+// map can be used to rewrite references.
+//
+// Pages are read with the keyset ListTodos of the source, which costs the
+// same for every page. Todos changed after they were copied keep their old
+// copy, so the source should not be written to while a transfer is running.
+//
+//	go run ./cmd/transfer -from mongo -to postgres -batch 500
+//	go run ./cmd/transfer -from mongo -to postgres -dry-run
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"newFeatures/database"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+type checkpoint struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// After is the last source todo read, nil before the first page.
+	After *models.TodoKey `json:"after,omitempty"`
+	// Waiting lists the source ids of the subtasks read before their
+	// parent was copied, which the second pass copies.
+	Waiting []string `json:"waiting,omitempty"`
+}
+
+type idMapping struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type summary struct {
+	Read    int64
+	Created int64
+	Skipped int64
+}
+
+func main() {
+	from := flag.String("from", "", "backend to read todos from")
+	to := flag.String("to", "", "backend to write todos to")
+	batch := flag.Int64("batch", 100, "number of todos read per page")
+	checkpointPath := flag.String("checkpoint", "transfer_checkpoint.json", "file that records the next page to read")
+	idsPath := flag.String("ids", "transfer_ids.jsonl", "file that records the source to target id mapping")
+	dryRun := flag.Bool("dry-run", false, "read the source and report what would be copied without writing anything")
+	envFile := flag.String("env", ".env", "file with the connection settings")
+	flag.Parse()
+
+	if err := godotenv.Load(*envFile); err != nil {
+		logrus.Fatalf("Error loading %s file. %s", *envFile, err.Error())
+	}
+	if *from == "" || *to == "" {
+		logrus.Fatalf("both -from and -to must be set")
+	}
+	if *from == *to {
+		logrus.Fatalf("source and target must differ")
+	}
+	if *from == repository.MemoryDB || *to == repository.MemoryDB {
+		logrus.Fatalf("the memory backend does not outlive the process and cannot be transferred")
+	}
+	if *batch < 1 {
+		logrus.Fatalf("-batch must be positive")
+	}
+
+	source, err := openTodoStore(*from)
+	if err != nil {
+		logrus.Fatalf("Error occurred while opening the source: %s", err.Error())
+	}
+	target, err := openTodoStore(*to)
+	if err != nil {
+		logrus.Fatalf("Error occurred while opening the target: %s", err.Error())
+	}
+
+	cp, err := loadCheckpoint(*checkpointPath, *from, *to)
+	if err != nil {
+		logrus.Fatalf("Error occurred while loading the checkpoint: %s", err.Error())
+	}
+	ids, err := loadIDs(*idsPath)
+	if err != nil {
+		logrus.Fatalf("Error occurred while loading the id map: %s", err.Error())
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+	// The todos of every owner are copied and keep their owner.
+	ctx = repository.WithAllOwners(ctx)
+
+	var sum summary
+	if *dryRun {
+		sum, err = plan(ctx, source, cp, ids, *batch)
+	} else {
+		sum, err = transfer(ctx, source, target, cp, ids, *batch, *checkpointPath, *idsPath)
+	}
+	printSummary(ctx, *dryRun, cp, sum, source, target)
+	if err != nil {
+		logrus.Fatalf("Transfer stopped: %s", err.Error())
+	}
+}
+
+func openTodoStore(dbType string) (repository.TodoStore, error) {
+	db, err := database.Open(dbType)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := repository.NewRepository(dbType, db)
+	if err != nil {
+		return nil, err
+	}
+	return repo.TodoStore, nil
+}
+
+// loadCheckpoint returns the stored checkpoint, or a fresh one starting at the
+// first page. A checkpoint written for another pair of backends is refused,
+// since its position would point elsewhere.
+func loadCheckpoint(path, from, to string) (*checkpoint, error) {
+	cp := &checkpoint{From: from, To: to}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored checkpoint
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if stored.From != from || stored.To != to {
+		return nil, fmt.Errorf("%s was written for %s -> %s", path, stored.From, stored.To)
+	}
+	return &stored, nil
+}
+
+// saveCheckpoint writes the checkpoint to a temporary file and renames it, so
+// a crash never leaves a half-written checkpoint behind.
+func saveCheckpoint(path string, cp *checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadIDs(path string) (map[string]string, error) {
+	ids := make(map[string]string)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m idMapping
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			// Only the last line can be torn by a crash; the todo it
+			// describes is copied again.
+			logrus.Warnf("Skipping unreadable id map line %q: %s", scanner.Text(), err)
+			continue
+		}
+		// The migration mode of the server shares the file and records the
+		// todos it deletes with an empty target.
+		if m.Target == "" {
+			delete(ids, m.Source)
+		} else {
+			ids[m.Source] = m.Target
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// pageQuery returns the query of the source page after cp.After.
+func pageQuery(cp *checkpoint, batch int64) models.TodoQuery {
+	return models.TodoQuery{Sort: models.TodoSort{Field: models.SortByID}, After: cp.After, Limit: batch}
+}
+
+// position describes where a page starts for messages.
+func position(after *models.TodoKey) string {
+	if after == nil {
+		return "at the start"
+	}
+	return "after todo " + after.ID
+}
+
+// lastKey returns the position after the last todo of a page.
+func lastKey(todos []models.Todo) *models.TodoKey {
+	last := todos[len(todos)-1]
+	createdAt := last.CreatedAt
+	return &models.TodoKey{ID: last.ID, Title: last.Title, CreatedAt: &createdAt}
+}
+
+func transfer(ctx context.Context, source, target repository.TodoStore, cp *checkpoint, ids map[string]string, batch int64, checkpointPath, idsPath string) (summary, error) {
+	var sum summary
+	f, err := os.OpenFile(idsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+
+	copyOne := func(todo models.Todo) error {
+		sourceID := todo.ID
+		copied := copyTodo(todo, ids)
+		targetID, err := target.CreateTodo(ctx, &copied)
+		if err != nil {
+			return fmt.Errorf("copying todo %s: %w", sourceID, err)
+		}
+		if err := enc.Encode(idMapping{Source: sourceID, Target: targetID}); err != nil {
+			return fmt.Errorf("recording id of todo %s: %w", sourceID, err)
+		}
+		ids[sourceID] = targetID
+		sum.Created++
+		return nil
+	}
+	save := func() error {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		if err := saveCheckpoint(checkpointPath, cp); err != nil {
+			return fmt.Errorf("saving checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	for {
+		todos, err := source.ListTodos(ctx, pageQuery(cp, batch))
+		if err != nil {
+			return sum, fmt.Errorf("reading the page %s: %w", position(cp.After), err)
+		}
+		for _, todo := range todos {
+			sum.Read++
+			if _, ok := ids[todo.ID]; ok {
+				sum.Skipped++
+				continue
+			}
+			// Backends that order by UUID return subtasks before their
+			// parent; they wait for the second pass.
+			if _, ok := ids[todo.ParentID]; todo.ParentID != "" && !ok {
+				cp.Waiting = append(cp.Waiting, todo.ID)
+				continue
+			}
+			if err := copyOne(todo); err != nil {
+				return sum, err
+			}
+		}
+		if len(todos) > 0 {
+			cp.After = lastKey(todos)
+		}
+		if err := save(); err != nil {
+			return sum, err
+		}
+		if int64(len(todos)) < batch {
+			break
+		}
+		logrus.Infof("Copied %d todos so far, %d subtasks wait for their parent", sum.Created, len(cp.Waiting))
+	}
+
+	// Every todo but the waiting subtasks is copied now. Each round copies
+	// the subtasks whose parent is not waiting itself: either the parent
+	// is copied or it is not in the source anymore.
+	for len(cp.Waiting) > 0 {
+		waiting := make(map[string]*models.Todo, len(cp.Waiting))
+		for _, id := range cp.Waiting {
+			if _, ok := ids[id]; ok {
+				continue
+			}
+			todo, err := source.GetTodoByID(ctx, id)
+			if errors.Is(err, repository.ErrTodoNotFound) {
+				continue
+			}
+			if err != nil {
+				return sum, fmt.Errorf("reading todo %s: %w", id, err)
+			}
+			waiting[id] = todo
+		}
+		next := []string{}
+		for _, id := range cp.Waiting {
+			todo, ok := waiting[id]
+			if !ok {
+				continue
+			}
+			if _, ok := waiting[todo.ParentID]; ok {
+				next = append(next, id)
+				continue
+			}
+			if err := copyOne(*todo); err != nil {
+				return sum, err
+			}
+		}
+		cp.Waiting = next
+		if err := save(); err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// copyTodo prepares a source todo for the target: it keeps every field,
+// its owner and timestamps included, but not its id and version, which the
+// target assigns. Lists are not transferred, so the todo is put in none. A
+// subtask points at the copy of its parent; one whose parent is not in the
+// source anymore becomes a top-level todo.
+func copyTodo(todo models.Todo, ids map[string]string) models.Todo {
+	if todo.ParentID != "" {
+		parentID, ok := ids[todo.ParentID]
+		if !ok {
+			logrus.Warnf("Parent %s of todo %s was not copied, the copy has no parent", todo.ParentID, todo.ID)
+		}
+		todo.ParentID = parentID
+	}
+	todo.ID = ""
+	todo.Version = 0
+	todo.ListID = ""
+	return todo
+}
+
+// plan walks the same pages as transfer without writing to the target or
+// touching the checkpoint and id map files.
+func plan(ctx context.Context, source repository.TodoStore, cp *checkpoint, ids map[string]string, batch int64) (summary, error) {
+	var sum summary
+	query := pageQuery(cp, batch)
+	for {
+		todos, err := source.ListTodos(ctx, query)
+		if err != nil {
+			return sum, fmt.Errorf("reading the page %s: %w", position(query.After), err)
+		}
+		for _, todo := range todos {
+			sum.Read++
+			if _, ok := ids[todo.ID]; ok {
+				sum.Skipped++
+			} else {
+				sum.Created++
+			}
+		}
+		if int64(len(todos)) < batch {
+			// The waiting subtasks were read before but not copied.
+			sum.Created += int64(len(cp.Waiting))
+			return sum, nil
+		}
+		query.After = lastKey(todos)
+	}
+}
+
+func printSummary(ctx context.Context, dryRun bool, cp *checkpoint, sum summary, source, target repository.TodoStore) {
+	verb := "created"
+	if dryRun {
+		verb = "to create"
+	}
+	fmt.Printf("%s -> %s, checkpoint %s, %d subtasks waiting\n", cp.From, cp.To, position(cp.After), len(cp.Waiting))
+	fmt.Printf("read: %d, %s: %d, already copied: %d\n", sum.Read, verb, sum.Created, sum.Skipped)
+	if count, err := source.CountTodos(ctx); err == nil {
+		fmt.Printf("todos in %s: %d\n", cp.From, count)
+	}
+	if count, err := target.CountTodos(ctx); err == nil {
+		fmt.Printf("todos in %s: %d\n", cp.To, count)
+	}
+}
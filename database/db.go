@@ -3,17 +3,22 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go"
 	"github.com/elastic/go-elasticsearch/v8"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gocql/gocql"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -32,6 +37,9 @@ type MongoDB struct {
 	Port     string
 	Username string
 	Password string
+	// Database and Collection hold the todos, mydb and todos when unset.
+	Database   string
+	Collection string
 }
 
 type ElasticSearchDB struct {
@@ -46,6 +54,9 @@ type CassandraDB struct {
 	Keyspace string
 	Username string
 	Password string
+	// Replication is "SimpleStrategy:<factor>" or
+	// "NetworkTopologyStrategy:<dc>=<factor>,<dc>=<factor>".
+	Replication string
 }
 
 type MariaDB struct {
@@ -64,6 +75,10 @@ type ClickHouseDB struct {
 	DBName   string
 }
 
+type SQLiteDB struct {
+	Path string
+}
+
 type CockroachDB struct {
 	Host     string
 	Port     string
@@ -72,9 +87,13 @@ type CockroachDB struct {
 	DBName   string
 }
 
+func (database PostgresDB) dsn() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		database.Username, database.Password, database.Host, database.Port, database.DBName, database.SSLMode)
+}
+
 func NewPostgresDB(database PostgresDB) (*sql.DB, error) {
-	db, err := sql.Open("postgres", fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		database.Username, database.Password, database.Host, database.Port, database.DBName, database.SSLMode))
+	db, err := sql.Open("postgres", database.dsn())
 	if err != nil {
 
 		return nil, fmt.Errorf("error connecting to database:%s", err)
@@ -84,38 +103,25 @@ func NewPostgresDB(database PostgresDB) (*sql.DB, error) {
 		logrus.Errorf("DB ping error:%s", err)
 		return nil, err
 	}
-	_, err = db.Exec(TODO_SCHEMA)
-	if err != nil {
-		logrus.Errorf("Error executing initial migration:%s", err)
-		return nil, fmt.Errorf("error executing initial migration:%s", err)
-	}
-	_, err = db.Exec(USER_SCHEMA)
-	if err != nil {
-		logrus.Errorf("Error executing initial migration:%s", err)
-		return nil, fmt.Errorf("error executing initial migration:%s", err)
-	}
 	return db, nil
 }
 
-const TODO_SCHEMA = `
-	CREATE TABLE IF NOT EXISTS todos (
-		id serial not null primary key,
-		title varchar(225) NOT NULL,
-		done bool DEFAULT FALSE
-	);
-`
-const USER_SCHEMA = `
-CREATE TABLE IF NOT EXISTS users
-(
-    id serial not null primary key,
-    name varchar(225) not null,
-    email varchar(225) not null UNIQUE,
-    phone varchar(225) not null UNIQUE,
-    password varchar(225) not null,
-    role varchar(225) not null default 'USER',
-    CONSTRAINT proper_email CHECK (email ~* '^[A-Za-z0-9._+%-]+@[A-Za-z0-9.-]+[.][A-Za-z]+$')
-);
-`
+// NewPostgresListener opens a dedicated connection that receives the
+// notifications sent on channel. It reconnects on its own when the
+// connection drops and then sends a nil notification, after which anything
+// notified in between has to be assumed missed.
+func NewPostgresListener(database PostgresDB, channel string) (*pq.Listener, error) {
+	listener := pq.NewListener(database.dsn(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.Errorf("Postgres listener: %s", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
 
 func ConnectToMongo(database MongoDB) (*mongo.Client, error) {
 	mongoURI := fmt.Sprintf("mongodb://%s:%s@%s:%s",
@@ -141,6 +147,82 @@ func ConnectToMongo(database MongoDB) (*mongo.Client, error) {
 	return client, nil
 }
 
+// todoValidator is installed on the todo collection so that the server
+// rejects documents the repository could not decode.
+var todoValidator = bson.M{
+	"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"required": bson.A{"title", "done"},
+		"properties": bson.M{
+			"owner_id":    bson.M{"bsonType": "string", "minLength": 1},
+			"title":       bson.M{"bsonType": "string", "minLength": 1, "maxLength": 225},
+			"description": bson.M{"bsonType": "string"},
+			"done":        bson.M{"bsonType": "bool"},
+			"due_at":      bson.M{"bsonType": bson.A{"date", "null"}},
+			"priority":    bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0, "maximum": 3},
+			"created_at":  bson.M{"bsonType": "date"},
+			"updated_at":  bson.M{"bsonType": "date"},
+		},
+	},
+}
+
+// NewMongoTodos connects to MongoDB and prepares the todo collection: it is
+// created with todoValidator, or has the validator updated if it already
+// exists, and the indexes used by the list and search paths are created.
+func NewMongoTodos(database MongoDB) (*mongo.Collection, error) {
+	if database.Database == "" {
+		database.Database = "mydb"
+	}
+	if database.Collection == "" {
+		database.Collection = "todos"
+	}
+	client, err := ConnectToMongo(database)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := client.Database(database.Database)
+	err = db.CreateCollection(ctx, database.Collection, options.CreateCollection().
+		SetValidator(todoValidator).
+		SetValidationLevel("strict").
+		SetValidationAction("error"))
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Name == "NamespaceExists" {
+		err = db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: database.Collection},
+			{Key: "validator", Value: todoValidator},
+			{Key: "validationLevel", Value: "strict"},
+			{Key: "validationAction", Value: "error"},
+		}).Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error installing the todo validator: %s", err)
+	}
+
+	collection := db.Collection(database.Collection)
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Listing the todos of one owner, in _id order.
+		{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "_id", Value: 1}}},
+		// Listing filtered by status, in _id order.
+		{Keys: bson.D{{Key: "done", Value: 1}, {Key: "_id", Value: 1}}},
+		// Listing sorted by title, in either direction.
+		{Keys: bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+		// Listing sorted by creation time, and the created and due ranges.
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "due_at", Value: 1}}},
+		// Searching by title.
+		{Keys: bson.D{{Key: "title", Value: "text"}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating todo indexes: %s", err)
+	}
+
+	return collection, nil
+}
+
 func NewElasticSearchDB(database ElasticSearchDB) (*elasticsearch.Client, error) {
 
 	cfg := elasticsearch.Config{
@@ -158,45 +240,99 @@ func NewElasticSearchDB(database ElasticSearchDB) (*elasticsearch.Client, error)
 		return nil, err
 	}
 
-	res, err := client.Indices.Exists([]string{database.Index})
-	if err != nil {
+	if err := EnsureElasticIndex(context.Background(), client, database.Index); err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode == http.StatusNotFound {
-		createRes, err := client.Indices.Create(database.Index)
-		if err != nil {
-			return nil, err
-		}
-		defer createRes.Body.Close()
+	return client, nil
+}
 
-		if createRes.IsError() {
-			return nil, fmt.Errorf("failed to create index: %s", createRes.String())
-		}
+func ConnectToCassandra(database CassandraDB) (*gocql.Session, error) {
+	if err := createKeyspace(database); err != nil {
+		return nil, fmt.Errorf("error creating keyspace: %s", err)
 	}
 
-	return client, nil
+	cluster := newCassandraCluster(database)
+	cluster.Keyspace = database.Keyspace
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
 }
 
-func ConnectToCassandra(database CassandraDB) (*gocql.Session, error) {
+func newCassandraCluster(database CassandraDB) *gocql.ClusterConfig {
 	cluster := gocql.NewCluster(database.Host)
 	cluster.Consistency = gocql.Quorum
-	cluster.Keyspace = database.Keyspace
 	cluster.Authenticator = gocql.PasswordAuthenticator{
 		Username: database.Username,
 		Password: database.Password,
 	}
-	session, err := cluster.CreateSession()
+	return cluster
+}
+
+var cqlIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// createKeyspace creates the keyspace with the configured replication if it
+// does not exist yet. The tables are created by the migrations.
+func createKeyspace(database CassandraDB) error {
+	if !cqlIdentifier.MatchString(database.Keyspace) {
+		return fmt.Errorf("invalid keyspace name %q", database.Keyspace)
+	}
+	replication, err := cassandraReplication(database.Replication)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return session, nil
+	session, err := newCassandraCluster(database).CreateSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return session.Query(fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s", database.Keyspace, replication)).Exec()
+}
+
+// cassandraReplication turns the Replication setting into a CQL replication
+// map. An empty setting means SimpleStrategy with a single replica.
+func cassandraReplication(spec string) (string, error) {
+	if spec == "" {
+		spec = "SimpleStrategy:1"
+	}
+	class, options, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid replication %q", spec)
+	}
+
+	switch class {
+	case "SimpleStrategy":
+		factor, err := strconv.Atoi(options)
+		if err != nil || factor < 1 {
+			return "", fmt.Errorf("invalid replication factor %q", options)
+		}
+		return fmt.Sprintf("{'class': 'SimpleStrategy', 'replication_factor': %d}", factor), nil
+	case "NetworkTopologyStrategy":
+		parts := []string{"'class': 'NetworkTopologyStrategy'"}
+		for _, dc := range strings.Split(options, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(dc), "=")
+			factor, err := strconv.Atoi(value)
+			if !ok || !cqlIdentifier.MatchString(name) || err != nil || factor < 1 {
+				return "", fmt.Errorf("invalid datacenter replication %q", dc)
+			}
+			parts = append(parts, fmt.Sprintf("'%s': %d", name, factor))
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported replication class %q", class)
+	}
 }
 
 func NewMariaDB(database MariaDB) (*sql.DB, error) {
-	dataSourceName := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", database.Username, database.Password, database.Host, database.Port, database.DBName)
+	// clientFoundRows makes UPDATE report matched rather than changed rows,
+	// so an update that leaves a todo unchanged is not mistaken for a missing one.
+	// parseTime scans DATETIME columns into time.Time.
+	dataSourceName := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?clientFoundRows=true&parseTime=true", database.Username, database.Password, database.Host, database.Port, database.DBName)
 	db, err := sql.Open("mysql", dataSourceName)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %s", err)
@@ -207,22 +343,9 @@ func NewMariaDB(database MariaDB) (*sql.DB, error) {
 		return nil, fmt.Errorf("error pinging database: %s", err)
 	}
 
-	_, err = db.Exec(TODO_SCHEMA_MariaDB)
-	if err != nil {
-		return nil, fmt.Errorf("error executing initial migration: %s", err)
-	}
-
 	return db, nil
 }
 
-const TODO_SCHEMA_MariaDB = `
-	CREATE TABLE IF NOT EXISTS todos (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		title VARCHAR(225) NOT NULL,
-		completed BOOLEAN DEFAULT FALSE
-	);
-`
-
 func NewClickHouseDB(database ClickHouseDB) (*sql.DB, error) {
 	connect, err := sql.Open("clickhouse", fmt.Sprintf("tcp://%s:%s?username=%s&password=%s&database=%s", database.Host, database.Port, database.Username, database.Password, database.DBName))
 	if err != nil {
@@ -249,18 +372,19 @@ func NewCockroachDB(database CockroachDB) (*sql.DB, error) {
 		return nil, fmt.Errorf("error pinging database: %s", err)
 	}
 
-	_, err = db.Exec(TODO_SCHEMA_CockroachDB)
+	return db, nil
+}
+
+func NewSQLiteDB(database SQLiteDB) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", database.Path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite database: %s", err)
+	}
+
+	err = db.Ping()
 	if err != nil {
-		return nil, fmt.Errorf("error executing initial migration: %s", err)
+		return nil, fmt.Errorf("error pinging database: %s", err)
 	}
 
 	return db, nil
 }
-
-const TODO_SCHEMA_CockroachDB = `
-	CREATE TABLE IF NOT EXISTS todos (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		title VARCHAR(225) NOT NULL,
-		completed BOOL DEFAULT FALSE
-	);
-`
@@ -0,0 +1,482 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sirupsen/logrus"
+)
+
+// todoIndexBody holds the settings and mapping of a todo index. title is
+// analyzed three ways: as plain text, as edge n-grams in title.prefix for
+// prefix search, and as a keyword in title.keyword for sorting.
+const todoIndexBody = `{
+	"settings": {
+		"analysis": {
+			"tokenizer": {
+				"todo_edge_ngram": {
+					"type": "edge_ngram",
+					"min_gram": 1,
+					"max_gram": 20,
+					"token_chars": ["letter", "digit"]
+				}
+			},
+			"analyzer": {
+				"todo_prefix": {
+					"type": "custom",
+					"tokenizer": "todo_edge_ngram",
+					"filter": ["lowercase"]
+				},
+				"todo_prefix_search": {
+					"type": "custom",
+					"tokenizer": "standard",
+					"filter": ["lowercase"]
+				}
+			}
+		}
+	},
+	"mappings": {
+		"dynamic": "strict",
+		"properties": {
+			"id": {"type": "keyword"},
+			"owner_id": {"type": "keyword"},
+			"parent_id": {"type": "keyword"},
+			"title": {
+				"type": "text",
+				"fields": {
+					"prefix": {
+						"type": "text",
+						"analyzer": "todo_prefix",
+						"search_analyzer": "todo_prefix_search"
+					},
+					"keyword": {"type": "keyword", "ignore_above": 256}
+				}
+			},
+			"description": {"type": "text"},
+			"completed": {"type": "boolean"},
+			"due_at": {"type": "date"},
+			"priority": {"type": "byte"},
+			"created_at": {"type": "date"},
+			"updated_at": {"type": "date"}
+		}
+	}
+}`
+
+// EnsureElasticIndex makes alias point at a todo index with the current
+// mapping. Reads and writes always go through the alias; the indices behind
+// it are named <alias>_v<N>. A plain index named like the alias, as created
+// by older versions, is copied into <alias>_v1 and replaced by the alias.
+// An existing index gets the fields added to the mapping since it was
+// created; changed fields need ReindexElastic.
+func EnsureElasticIndex(ctx context.Context, client *elasticsearch.Client, alias string) error {
+	current, err := aliasedIndex(ctx, client, alias)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		return updateTodoMapping(ctx, client, current)
+	}
+
+	res, err := client.Indices.Exists([]string{alias}, client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	legacy := res.StatusCode == http.StatusOK
+
+	index := versionedIndex(alias, 1)
+	if err := createTodoIndex(ctx, client, index); err != nil {
+		return err
+	}
+	if legacy {
+		logrus.Infof("Copying legacy index %s into %s", alias, index)
+		if err := reindex(ctx, client, alias, index, nil); err != nil {
+			return err
+		}
+		return updateAliases(ctx, client,
+			map[string]interface{}{"add": map[string]interface{}{"index": index, "alias": alias, "is_write_index": true}},
+			map[string]interface{}{"remove_index": map[string]interface{}{"index": alias}},
+		)
+	}
+	return updateAliases(ctx, client,
+		map[string]interface{}{"add": map[string]interface{}{"index": index, "alias": alias, "is_write_index": true}},
+	)
+}
+
+// reindexMargin widens the catch-up passes of ReindexElastic to allow for
+// clock skew between the servers that stamp updated_at and this process.
+const reindexMargin = time.Minute
+
+// ReindexElastic copies the index behind alias into a new index created with
+// the current mapping and then moves the alias to it in one atomic update.
+// It returns the name of the new index. Reads and writes go on while the
+// bulk of the todos is copied; the todos written meanwhile, which have a
+// newer updated_at, are copied again by catch-up passes. Only for the last
+// pass, which also drops the todos deleted meanwhile, writes to the old
+// index are blocked and fail. The old index is kept, writable again, so the
+// swap can be undone.
+func ReindexElastic(ctx context.Context, client *elasticsearch.Client, alias string) (string, error) {
+	current, err := aliasedIndex(ctx, client, alias)
+	if err != nil {
+		return "", err
+	}
+	if current == "" {
+		return "", fmt.Errorf("alias %s does not exist", alias)
+	}
+
+	version := 1
+	if n, err := strconv.Atoi(strings.TrimPrefix(current, alias+"_v")); err == nil {
+		version = n
+	}
+	index := versionedIndex(alias, version+1)
+	if err := createTodoIndex(ctx, client, index); err != nil {
+		return "", err
+	}
+	since := time.Now()
+	if err := reindex(ctx, client, current, index, nil); err != nil {
+		return "", err
+	}
+	// A catch-up pass while writes still go on keeps the blocked one short.
+	next := time.Now()
+	if err := reindex(ctx, client, current, index, updatedSince(since)); err != nil {
+		return "", err
+	}
+
+	if err := blockWrites(ctx, client, current, true); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := blockWrites(context.Background(), client, current, false); err != nil {
+			logrus.Errorf("ReindexElastic: failed to unblock writes to %s: %s", current, err)
+		}
+	}()
+	if err := reindex(ctx, client, current, index, updatedSince(next)); err != nil {
+		return "", err
+	}
+	if err := dropDeleted(ctx, client, current, index); err != nil {
+		return "", err
+	}
+	err = updateAliases(ctx, client,
+		map[string]interface{}{"remove": map[string]interface{}{"index": current, "alias": alias}},
+		map[string]interface{}{"add": map[string]interface{}{"index": index, "alias": alias, "is_write_index": true}},
+	)
+	if err != nil {
+		return "", err
+	}
+	return index, nil
+}
+
+// updatedSince selects the todos updated at or after t, less reindexMargin.
+func updatedSince(t time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			"updated_at": map[string]interface{}{"gte": t.Add(-reindexMargin).UTC().Format(time.RFC3339Nano)},
+		},
+	}
+}
+
+// blockWrites sets or lifts the write block of index. Reads keep working.
+func blockWrites(ctx context.Context, client *elasticsearch.Client, index string, block bool) error {
+	body, err := json.Marshal(map[string]interface{}{"index.blocks.write": block})
+	if err != nil {
+		return err
+	}
+	res, err := client.Indices.PutSettings(bytes.NewReader(body),
+		client.Indices.PutSettings.WithIndex(index),
+		client.Indices.PutSettings.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to set the write block of %s: %s", index, res.String())
+	}
+	return nil
+}
+
+// dropDeleted deletes from dest the todos that are no longer in source.
+// Writes to source must be blocked and all its todos copied to dest, so
+// dest holds more todos exactly when some were deleted during the copy;
+// only then are the ids of dest looked up in source.
+func dropDeleted(ctx context.Context, client *elasticsearch.Client, source, dest string) error {
+	sourceCount, err := countDocs(ctx, client, source)
+	if err != nil {
+		return err
+	}
+	destCount, err := countDocs(ctx, client, dest)
+	if err != nil {
+		return err
+	}
+	if sourceCount == destCount {
+		return nil
+	}
+
+	res, err := client.Search(
+		client.Search.WithIndex(dest),
+		client.Search.WithBody(strings.NewReader(`{"_source": false, "sort": ["_doc"]}`)),
+		client.Search.WithSize(1000),
+		client.Search.WithScroll(time.Minute),
+		client.Search.WithContext(ctx),
+	)
+	for {
+		if err != nil {
+			return err
+		}
+		var page struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []struct {
+					ID string `json:"_id"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if res.IsError() {
+			res.Body.Close()
+			return fmt.Errorf("failed to list the todos of %s: %s", dest, res.String())
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(page.Hits.Hits) == 0 {
+			clearRes, err := client.ClearScroll(client.ClearScroll.WithScrollID(page.ScrollID))
+			if err == nil {
+				clearRes.Body.Close()
+			}
+			return nil
+		}
+		ids := make([]string, len(page.Hits.Hits))
+		for i, hit := range page.Hits.Hits {
+			ids[i] = hit.ID
+		}
+		if err := deleteMissing(ctx, client, source, dest, ids); err != nil {
+			return err
+		}
+		res, err = client.Scroll(
+			client.Scroll.WithScrollID(page.ScrollID),
+			client.Scroll.WithScroll(time.Minute),
+			client.Scroll.WithContext(ctx),
+		)
+	}
+}
+
+// deleteMissing deletes the todos with ids from dest that source lacks.
+func deleteMissing(ctx context.Context, client *elasticsearch.Client, source, dest string, ids []string) error {
+	body, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return err
+	}
+	res, err := client.Mget(bytes.NewReader(body),
+		client.Mget.WithIndex(source),
+		client.Mget.WithSource("false"),
+		client.Mget.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to look up todos in %s: %s", source, res.String())
+	}
+	var found struct {
+		Docs []struct {
+			ID    string `json:"_id"`
+			Found bool   `json:"found"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&found); err != nil {
+		return err
+	}
+
+	var bulk bytes.Buffer
+	for _, doc := range found.Docs {
+		if doc.Found {
+			continue
+		}
+		action, err := json.Marshal(map[string]interface{}{"delete": map[string]interface{}{"_index": dest, "_id": doc.ID}})
+		if err != nil {
+			return err
+		}
+		bulk.Write(action)
+		bulk.WriteByte('\n')
+	}
+	if bulk.Len() == 0 {
+		return nil
+	}
+	bulkRes, err := client.Bulk(&bulk, client.Bulk.WithRefresh("true"), client.Bulk.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer bulkRes.Body.Close()
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if bulkRes.IsError() {
+		return fmt.Errorf("failed to delete todos from %s: %s", dest, bulkRes.String())
+	}
+	if err := json.NewDecoder(bulkRes.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return fmt.Errorf("failed to delete some todos from %s", dest)
+	}
+	return nil
+}
+
+func countDocs(ctx context.Context, client *elasticsearch.Client, index string) (int64, error) {
+	res, err := client.Count(client.Count.WithIndex(index), client.Count.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("failed to count the todos of %s: %s", index, res.String())
+	}
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+func versionedIndex(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// aliasedIndex returns the index alias points at, or "" if there is no such
+// alias. With several indices behind the alias the newest one is returned.
+func aliasedIndex(ctx context.Context, client *elasticsearch.Client, alias string) (string, error) {
+	res, err := client.Indices.GetAlias(client.Indices.GetAlias.WithName(alias), client.Indices.GetAlias.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.IsError() {
+		return "", fmt.Errorf("failed to get alias %s: %s", alias, res.String())
+	}
+
+	var indices map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) < len(names[j]) || len(names[i]) == len(names[j]) && names[i] < names[j]
+	})
+	return names[len(names)-1], nil
+}
+
+func createTodoIndex(ctx context.Context, client *elasticsearch.Client, index string) error {
+	res, err := client.Indices.Create(index,
+		client.Indices.Create.WithBody(strings.NewReader(todoIndexBody)),
+		client.Indices.Create.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to create index %s: %s", index, res.String())
+	}
+	return nil
+}
+
+// updateTodoMapping puts the mapping of todoIndexBody on index. Elasticsearch
+// accepts new fields and rejects changes to existing ones.
+func updateTodoMapping(ctx context.Context, client *elasticsearch.Client, index string) error {
+	var body struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(todoIndexBody), &body); err != nil {
+		return err
+	}
+	res, err := client.Indices.PutMapping([]string{index}, bytes.NewReader(body.Mappings),
+		client.Indices.PutMapping.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to update the mapping of index %s: %s", index, res.String())
+	}
+	return nil
+}
+
+// reindex copies the todos of source that match query, all of them for a
+// nil query, into dest, replacing the todos dest has under the same ids.
+func reindex(ctx context.Context, client *elasticsearch.Client, source, dest string, query map[string]interface{}) error {
+	from := map[string]interface{}{"index": source}
+	if query != nil {
+		from["query"] = query
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"source": from,
+		"dest":   map[string]interface{}{"index": dest},
+	})
+	if err != nil {
+		return err
+	}
+	res, err := client.Reindex(bytes.NewReader(body),
+		client.Reindex.WithWaitForCompletion(true),
+		client.Reindex.WithRefresh(true),
+		client.Reindex.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to reindex %s into %s: %s", source, dest, res.String())
+	}
+
+	var result struct {
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("failed to reindex %s into %s: %d documents failed, first: %s", source, dest, len(result.Failures), result.Failures[0])
+	}
+	return nil
+}
+
+func updateAliases(ctx context.Context, client *elasticsearch.Client, actions ...map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+	req := esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(body)}
+	res, err := req.Do(ctx, client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to update aliases: %s", res.String())
+	}
+	return nil
+}
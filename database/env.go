@@ -0,0 +1,196 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gocql/gocql"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Open connects to the backend named dbType using the connection settings
+// from the environment. The in-memory backend needs no connection, so Open
+// returns nil for it.
+func Open(dbType string) (interface{}, error) {
+	var db interface{}
+	var err error
+
+	switch dbType {
+	case "postgres":
+		db, err = postgresFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Postgres database: %s", err.Error())
+		}
+	case "mongo":
+		db, err = mongoFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize MongoDB: %s", err.Error())
+		}
+	case "elasticsearch":
+		db, err = elasticSearchFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize ElasticSearch: %s", err.Error())
+		}
+	case "cassandra":
+		db, err = cassandraFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Cassandra: %s", err.Error())
+		}
+	case "maria":
+		db, err = mariaFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize MariaDB: %s", err.Error())
+		}
+	case "clickhouse":
+		db, err = clickHouseFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize ClickHouseDB: %s", err.Error())
+		}
+	case "cockroach":
+		db, err = cockroachFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CockroachDB: %s", err.Error())
+		}
+	case "sqlite":
+		db, err = sqliteFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SQLite: %s", err.Error())
+		}
+	case "memory":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return db, nil
+}
+
+func postgresFromEnv() (*sql.DB, error) {
+	config, err := postgresConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresDB(config)
+}
+
+// PostgresListenerFromEnv listens on channel of the Postgres database
+// configured in the environment.
+func PostgresListenerFromEnv(channel string) (*pq.Listener, error) {
+	config, err := postgresConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresListener(config, channel)
+}
+
+func postgresConfigFromEnv() (PostgresDB, error) {
+	if os.Getenv("POSTGRES_HOST") == "" || os.Getenv("POSTGRES_PORT") == "" || os.Getenv("POSTGRES_USER") == "" || os.Getenv("POSTGRES_PASSWORD") == "" || os.Getenv("POSTGRES_DB") == "" || os.Getenv("POSTGRES_SSL_MODE") == "" {
+		return PostgresDB{}, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return PostgresDB{
+		Host:     os.Getenv("POSTGRES_HOST"),
+		Port:     os.Getenv("POSTGRES_PORT"),
+		Username: os.Getenv("POSTGRES_USER"),
+		Password: os.Getenv("POSTGRES_PASSWORD"),
+		DBName:   os.Getenv("POSTGRES_DB"),
+		SSLMode:  os.Getenv("POSTGRES_SSL_MODE"),
+	}, nil
+}
+
+func mongoFromEnv() (*mongo.Collection, error) {
+	if os.Getenv("MONGO_HOST") == "" || os.Getenv("MONGO_PORT") == "" || os.Getenv("MONGO_USERNAME") == "" || os.Getenv("MONGO_PASSWORD") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewMongoTodos(MongoDB{
+		Host:       os.Getenv("MONGO_HOST"),
+		Port:       os.Getenv("MONGO_PORT"),
+		Username:   os.Getenv("MONGO_USERNAME"),
+		Password:   os.Getenv("MONGO_PASSWORD"),
+		Database:   os.Getenv("MONGO_DATABASE"),
+		Collection: os.Getenv("MONGO_COLLECTION"),
+	})
+}
+
+func elasticSearchFromEnv() (*elasticsearch.Client, error) {
+	if os.Getenv("ELASTIC_HOST") == "" || os.Getenv("ELASTIC_USERNAME") == "" || os.Getenv("ELASTIC_PASSWORD") == "" || os.Getenv("ELASTIC_INDEX") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewElasticSearchDB(ElasticSearchDB{
+		Host:     os.Getenv("ELASTIC_HOST"),
+		Username: os.Getenv("ELASTIC_USERNAME"),
+		Password: os.Getenv("ELASTIC_PASSWORD"),
+		Index:    os.Getenv("ELASTIC_INDEX"),
+	})
+}
+
+func cassandraFromEnv() (*gocql.Session, error) {
+	if os.Getenv("CASSANDRA_HOST") == "" || os.Getenv("CASSANDRA_KEYSPACE") == "" || os.Getenv("CASSANDRA_USERNAME") == "" || os.Getenv("CASSANDRA_PASSWORD") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return ConnectToCassandra(CassandraDB{
+		Host:     os.Getenv("CASSANDRA_HOST"),
+		Keyspace: os.Getenv("CASSANDRA_KEYSPACE"),
+		Username: os.Getenv("CASSANDRA_USERNAME"),
+		Password: os.Getenv("CASSANDRA_PASSWORD"),
+		// Optional, SimpleStrategy:1 when unset.
+		Replication: os.Getenv("CASSANDRA_REPLICATION"),
+	})
+}
+
+func mariaFromEnv() (*sql.DB, error) {
+	if os.Getenv("MARIA_USER") == "" || os.Getenv("MARIA_PASSWORD") == "" || os.Getenv("MARIA_HOST") == "" || os.Getenv("MARIA_PORT") == "" || os.Getenv("MARIA_DB") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewMariaDB(MariaDB{
+		Host:     os.Getenv("MARIA_HOST"),
+		Port:     os.Getenv("MARIA_PORT"),
+		Username: os.Getenv("MARIA_USER"),
+		Password: os.Getenv("MARIA_PASSWORD"),
+		DBName:   os.Getenv("MARIA_DB"),
+	})
+}
+
+func clickHouseFromEnv() (*sql.DB, error) {
+	if os.Getenv("CLICKHOUSE_HOST") == "" || os.Getenv("CLICKHOUSE_PORT") == "" || os.Getenv("CLICKHOUSE_USER") == "" || os.Getenv("CLICKHOUSE_PASSWORD") == "" || os.Getenv("CLICKHOUSE_DB") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewClickHouseDB(ClickHouseDB{
+		Host:     os.Getenv("CLICKHOUSE_HOST"),
+		Port:     os.Getenv("CLICKHOUSE_PORT"),
+		Username: os.Getenv("CLICKHOUSE_USER"),
+		Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+		DBName:   os.Getenv("CLICKHOUSE_DB"),
+	})
+}
+
+func cockroachFromEnv() (*sql.DB, error) {
+	if os.Getenv("COCKROACH_HOST") == "" || os.Getenv("COCKROACH_PORT") == "" || os.Getenv("COCKROACH_USERNAME") == "" || os.Getenv("COCKROACH_PASSWORD") == "" || os.Getenv("COCKROACH_DB") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewCockroachDB(CockroachDB{
+		Host:     os.Getenv("COCKROACH_HOST"),
+		Port:     os.Getenv("COCKROACH_PORT"),
+		Username: os.Getenv("COCKROACH_USERNAME"),
+		Password: os.Getenv("COCKROACH_PASSWORD"),
+		DBName:   os.Getenv("COCKROACH_DB"),
+	})
+}
+
+func sqliteFromEnv() (*sql.DB, error) {
+	if os.Getenv("SQLITE_PATH") == "" {
+		return nil, fmt.Errorf("some of the required environment variables are not set")
+	}
+
+	return NewSQLiteDB(SQLiteDB{
+		Path: os.Getenv("SQLITE_PATH"),
+	})
+}
@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"context"
+	"newFeatures/models"
+	"sync"
+)
+
+// childLoader serves the children and progress fields of one GraphQL
+// request. The queries register the todos they return as roots; the first
+// field that asks for subtasks expands all the roots at once, one query per
+// level, and the fields of every todo within those trees are then answered
+// from what was read.
+type childLoader struct {
+	mu       sync.Mutex
+	roots    []models.Todo
+	children map[string][]models.Todo
+}
+
+type childLoaderKey struct{}
+
+// WithChildLoader gives the GraphQL request of ctx a loader of its own.
+func WithChildLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, childLoaderKey{}, &childLoader{children: make(map[string][]models.Todo)})
+}
+
+// addRoots registers todos returned by a query of the request of ctx.
+func addRoots(ctx context.Context, todos ...models.Todo) {
+	l, ok := ctx.Value(childLoaderKey{}).(*childLoader)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roots = append(l.roots, todos...)
+}
+
+// childTodos returns the subtasks of the todo with id. Without a loader in
+// ctx every call reads them anew.
+func (r *Resolver) childTodos(ctx context.Context, id string) ([]models.Todo, error) {
+	l, ok := ctx.Value(childLoaderKey{}).(*childLoader)
+	if !ok {
+		return r.todos().ChildTodos(ctx, id)
+	}
+	// The lock also makes the fields resolved concurrently wait for the
+	// expansion instead of reading the same subtasks themselves.
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if children, ok := l.children[id]; ok {
+		return children, nil
+	}
+	if len(l.roots) > 0 {
+		roots := l.roots
+		l.roots = nil
+		if err := r.todos().ExpandChildren(ctx, roots); err != nil {
+			return nil, err
+		}
+		l.add(roots)
+		if children, ok := l.children[id]; ok {
+			return children, nil
+		}
+	}
+	// Only todos below the depth ExpandChildren reads get here.
+	children, err := r.todos().ChildTodos(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	l.children[id] = children
+	return children, nil
+}
+
+// add records the subtasks ExpandChildren read for todos and their subtasks.
+func (l *childLoader) add(todos []models.Todo) {
+	for _, todo := range todos {
+		if todo.Children == nil {
+			continue
+		}
+		l.children[todo.ID] = todo.Children
+		l.add(todo.Children)
+	}
+}
@@ -39,5 +39,6 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		ctx.Set("Auth", customClaim)
 		ctx.Set("Authorization", header)
+		ctx.Request = ctx.Request.WithContext(service.WithCaller(ctx.Request.Context(), id, role))
 	}
 }
@@ -2,19 +2,159 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type BulkTodoItem struct {
+	// Id of the stored todo, unset if it failed.
+	ID    *string `json:"id,omitempty"`
+	Error *string `json:"error,omitempty"`
+}
+
+type BulkTodoResult struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// One item per input todo, in input order.
+	Items []*BulkTodoItem `json:"items"`
+}
+
+// Number of matching todos per completion state, over all pages.
+type CompletedFacet struct {
+	Completed    int `json:"completed"`
+	NotCompleted int `json:"notCompleted"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type TodoElastic struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+	ID string `json:"id"`
+	// The id of the user the todo belongs to.
+	OwnerID     string     `json:"ownerId"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	// From 0, none, to 3, high.
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// The todo this one is a subtask of, unset for a top-level todo.
+	ParentID *string `json:"parentId,omitempty"`
+	// The subtasks of the todo, by id.
+	Children []*TodoElastic `json:"children"`
+	// How many of the subtasks are completed; unset for a todo without subtasks.
+	Progress *TodoProgress `json:"progress,omitempty"`
+}
+
+type TodoElasticConnection struct {
+	Edges    []*TodoElasticEdge `json:"edges"`
+	PageInfo *PageInfo          `json:"pageInfo"`
+	// Number of todos matching the query, over all pages.
+	TotalCount int `json:"totalCount"`
+}
+
+type TodoElasticEdge struct {
+	Node *TodoElastic `json:"node"`
+	// Pass as after to continue with the todos following this one.
+	Cursor string `json:"cursor"`
+	// Relevance of the todo; 0 when listing without a query.
+	Score      float64  `json:"score"`
+	Highlights []string `json:"highlights"`
 }
 
 type TodoInput struct {
-	Title     string `json:"title"`
-	Completed *bool  `json:"completed,omitempty"`
+	Title       string     `json:"title"`
+	Description *string    `json:"description,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Priority    *int       `json:"priority,omitempty"`
+	// Makes the todo a subtask of the todo with this id.
+	ParentID *string `json:"parentId,omitempty"`
 }
 
 type TodoInputID struct {
-	ID        string  `json:"id"`
-	Title     *string `json:"title,omitempty"`
-	Completed *bool   `json:"completed,omitempty"`
+	ID          string     `json:"id"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	// Removes the due date; dueAt is ignored then.
+	ClearDueAt *bool `json:"clearDueAt,omitempty"`
+	Priority   *int  `json:"priority,omitempty"`
+	// With completed true, also completes all subtasks of the todo.
+	CompleteDescendants *bool `json:"completeDescendants,omitempty"`
+}
+
+type TodoProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+	// completed over total.
+	Ratio float64 `json:"ratio"`
+}
+
+type TodoSearchHit struct {
+	Todo  *TodoElastic `json:"todo"`
+	Score float64      `json:"score"`
+	// Title fragments with the matched parts wrapped in <em> tags.
+	Highlights []string `json:"highlights"`
+}
+
+type TodoSearchResult struct {
+	Hits []*TodoSearchHit `json:"hits"`
+	// Number of todos matching the query, over all pages.
+	Total          int             `json:"total"`
+	CompletedFacet *CompletedFacet `json:"completedFacet"`
+	// Spelling corrections for the words of the query.
+	Suggestions []string `json:"suggestions"`
+}
+
+// When the todos of a bulk request become visible to searches.
+type BulkRefresh string
+
+const (
+	// Wait until the todos can be searched before returning.
+	BulkRefreshWaitFor BulkRefresh = "WAIT_FOR"
+	// Return as soon as the todos are stored; searches see them within a second.
+	BulkRefreshNone BulkRefresh = "NONE"
+)
+
+var AllBulkRefresh = []BulkRefresh{
+	BulkRefreshWaitFor,
+	BulkRefreshNone,
+}
+
+func (e BulkRefresh) IsValid() bool {
+	switch e {
+	case BulkRefreshWaitFor, BulkRefreshNone:
+		return true
+	}
+	return false
+}
+
+func (e BulkRefresh) String() string {
+	return string(e)
+}
+
+func (e *BulkRefresh) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BulkRefresh(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BulkRefresh", str)
+	}
+	return nil
+}
+
+func (e BulkRefresh) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
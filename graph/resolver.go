@@ -2,8 +2,56 @@ package graph
 
 //go:generate go run github.com/99designs/gqlgen generate
 
-import "newFeatures/service"
+import (
+	"newFeatures/graph/model"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"newFeatures/service"
+)
 
 type Resolver struct {
 	Serv *service.Service
 }
+
+// todos returns the Elasticsearch backend the GraphQL schema is served from.
+func (r *Resolver) todos() service.Todo {
+	return r.Serv.Backend(repository.ElasticSearchDB)
+}
+
+// newTodo maps a todo input of the schema to a todo.
+func newTodo(input *model.TodoInput) models.Todo {
+	todo := models.Todo{Title: input.Title, DueAt: input.DueAt}
+	if input.Description != nil {
+		todo.Description = *input.Description
+	}
+	if input.Completed != nil {
+		todo.Done = *input.Completed
+	}
+	if input.Priority != nil {
+		todo.Priority = *input.Priority
+	}
+	if input.ParentID != nil {
+		todo.ParentID = *input.ParentID
+	}
+	return todo
+}
+
+// todoElastic maps a todo to its type in the schema. The children and the
+// progress have resolvers of their own, which read through childTodos.
+func todoElastic(todo *models.Todo) *model.TodoElastic {
+	result := &model.TodoElastic{
+		ID:          todo.ID,
+		OwnerID:     todo.OwnerID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Done,
+		DueAt:       todo.DueAt,
+		Priority:    todo.Priority,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+	if todo.ParentID != "" {
+		result.ParentID = &todo.ParentID
+	}
+	return result
+}
@@ -9,18 +9,16 @@ import (
 	"newFeatures/graph/generated"
 	"newFeatures/graph/model"
 	"newFeatures/models"
+	"strings"
 )
 
 // CreateTodoElastic is the resolver for the createTodoElastic field.
 func (r *mutationResolver) CreateTodoElastic(ctx context.Context, input model.TodoInput) (string, error) {
-	// Create a new instance of TodoElastic
-	todoElastic := &models.TodoElastic{
-		Title:     input.Title,
-		Completed: *input.Completed,
-	}
+	// Create a new todo
+	todo := newTodo(&input)
 
 	// Create document in Elasticsearch
-	id, err := r.Serv.TodoElasticService.CreateTodo(ctx, todoElastic)
+	id, err := r.todos().CreateTodo(ctx, &todo)
 	if err != nil {
 		return "", err
 	}
@@ -31,7 +29,7 @@ func (r *mutationResolver) CreateTodoElastic(ctx context.Context, input model.To
 // UpdateTodoElastic is the resolver for the updateTodoElastic field.
 func (r *mutationResolver) UpdateTodoElastic(ctx context.Context, input model.TodoInputID) (string, error) {
 	// Get the todo from Elasticsearch
-	todo, err := r.Serv.TodoElasticService.GetTodo(ctx, input.ID)
+	todo, err := r.todos().GetTodo(ctx, input.ID)
 	if err != nil {
 		return "", err
 	}
@@ -40,23 +38,39 @@ func (r *mutationResolver) UpdateTodoElastic(ctx context.Context, input model.To
 	if input.Title != nil {
 		todo.Title = *input.Title
 	}
+	if input.Description != nil {
+		todo.Description = *input.Description
+	}
 	if input.Completed != nil {
-		todo.Completed = *input.Completed
+		todo.Done = *input.Completed
+	}
+	if input.ClearDueAt != nil && *input.ClearDueAt {
+		todo.DueAt = nil
+	} else if input.DueAt != nil {
+		todo.DueAt = input.DueAt
+	}
+	if input.Priority != nil {
+		todo.Priority = *input.Priority
 	}
 
 	// Update the todo in Elasticsearch
-	id, err := r.Serv.TodoElasticService.UpdateTodo(ctx, todo)
+	err = r.todos().UpdateTodo(ctx, todo)
 	if err != nil {
 		return "", err
 	}
+	if todo.Done && input.CompleteDescendants != nil && *input.CompleteDescendants {
+		if _, err := r.todos().CompleteDescendants(ctx, todo.ID); err != nil {
+			return "", err
+		}
+	}
 
-	return id, nil
+	return todo.ID, nil
 }
 
 // DeleteTodoElastic is the resolver for the deleteTodoElastic field.
 func (r *mutationResolver) DeleteTodoElastic(ctx context.Context, id string) (bool, error) {
 	// Delete the todo from Elasticsearch
-	err := r.Serv.TodoElasticService.DeleteTodoByID(ctx, id)
+	_, err := r.todos().DeleteTodoByID(ctx, id)
 	if err != nil {
 		return false, err
 	}
@@ -64,19 +78,47 @@ func (r *mutationResolver) DeleteTodoElastic(ctx context.Context, id string) (bo
 	return true, nil
 }
 
+// BulkCreateTodosElastic is the resolver for the bulkCreateTodosElastic field.
+func (r *mutationResolver) BulkCreateTodosElastic(ctx context.Context, input []*model.TodoInput, refresh *model.BulkRefresh) (*model.BulkTodoResult, error) {
+	todos := make([]models.Todo, len(input))
+	for i, in := range input {
+		todos[i] = newTodo(in)
+	}
+	var policy string
+	if refresh != nil {
+		policy = strings.ToLower(refresh.String())
+	}
+
+	results, err := r.todos().BulkCreateTodos(ctx, todos, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.BulkTodoResult{Items: make([]*model.BulkTodoItem, len(results))}
+	for i := range results {
+		item := &model.BulkTodoItem{}
+		if results[i].Error != "" {
+			item.Error = &results[i].Error
+			res.Failed++
+		} else {
+			item.ID = &results[i].ID
+			res.Created++
+		}
+		res.Items[i] = item
+	}
+	return res, nil
+}
+
 // GetTodoElastic is the resolver for the getTodoElastic field.
 func (r *queryResolver) GetTodoElastic(ctx context.Context, id string) (*model.TodoElastic, error) {
 	// Get the todo from Elasticsearch
-	todo, err := r.Serv.TodoElasticService.GetTodo(ctx, id)
+	todo, err := r.todos().GetTodo(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	addRoots(ctx, *todo)
 
-	return &model.TodoElastic{
-		ID:        todo.ID,
-		Title:     todo.Title,
-		Completed: todo.Completed,
-	}, nil
+	return todoElastic(todo), nil
 }
 
 // GetTodosElastic is the resolver for the getTodosElastic field.
@@ -89,23 +131,20 @@ func (r *queryResolver) GetTodosElastic(ctx context.Context, page *int, limit *i
 	if limit != nil && *limit > 0 {
 		lim = int64(*limit)
 	}
-	todos, err := r.Serv.TodoElasticService.GetTodos(ctx, pg, lim)
+	todos, _, err := r.todos().GetTodos(ctx, pg, lim)
 	if err != nil {
 		return nil, err
 	}
+	addRoots(ctx, todos...)
 	var todoResults []*model.TodoElastic
 	for _, todo := range todos {
-		todoResults = append(todoResults, &model.TodoElastic{
-			ID:        todo.ID,
-			Title:     todo.Title,
-			Completed: todo.Completed,
-		})
+		todoResults = append(todoResults, todoElastic(&todo))
 	}
 	return todoResults, nil
 }
 
 // SearchTodosElastic is the resolver for the searchTodosElastic field.
-func (r *queryResolver) SearchTodosElastic(ctx context.Context, query string, page *int, limit *int) ([]*model.TodoElastic, error) {
+func (r *queryResolver) SearchTodosElastic(ctx context.Context, query string, page *int, limit *int) (*model.TodoSearchResult, error) {
 	// Set default values for page and limit
 	var pg, lim int64 = 1, 10
 	if page != nil && *page > 0 {
@@ -115,22 +154,110 @@ func (r *queryResolver) SearchTodosElastic(ctx context.Context, query string, pa
 		lim = int64(*limit)
 	}
 	// Search for todos in Elasticsearch
-	todos, err := r.Serv.TodoElasticService.SearchTodos(ctx, query, pg, lim)
+	result, err := r.todos().SearchTodos(ctx, query, pg, lim)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert todos to the format expected by the GraphQL schema
-	var todoResults []*model.TodoElastic
-	for _, todo := range todos {
-		todoResults = append(todoResults, &model.TodoElastic{
-			ID:        todo.ID,
-			Title:     todo.Title,
-			Completed: todo.Completed,
+	// Convert the result to the format expected by the GraphQL schema
+	hits := make([]*model.TodoSearchHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		addRoots(ctx, hit.Todo)
+		hits = append(hits, &model.TodoSearchHit{
+			Todo:       todoElastic(&hit.Todo),
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
 		})
 	}
 
-	return todoResults, nil
+	return &model.TodoSearchResult{
+		Hits:  hits,
+		Total: int(result.Total),
+		CompletedFacet: &model.CompletedFacet{
+			Completed:    int(result.Completed),
+			NotCompleted: int(result.NotCompleted),
+		},
+		Suggestions: result.Suggestions,
+	}, nil
+}
+
+// TodosElastic is the resolver for the todosElastic field.
+func (r *queryResolver) TodosElastic(ctx context.Context, query *string, first *int, after *string) (*model.TodoElasticConnection, error) {
+	var q, cursor string
+	var lim int64 = 10
+	if query != nil {
+		q = *query
+	}
+	if first != nil && *first > 0 {
+		lim = int64(*first)
+	}
+	if after != nil {
+		cursor = *after
+	}
+	result, err := r.todos().SearchTodosAfter(ctx, q, cursor, lim)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.TodoElasticEdge, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		addRoots(ctx, hit.Todo)
+		edges = append(edges, &model.TodoElasticEdge{
+			Node:       todoElastic(&hit.Todo),
+			Cursor:     hit.Cursor,
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+	pageInfo := &model.PageInfo{HasNextPage: result.HasMore}
+	if len(edges) > 0 {
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.TodoElasticConnection{
+		Edges:      edges,
+		PageInfo:   pageInfo,
+		TotalCount: int(result.Total),
+	}, nil
+}
+
+// SuggestTodosElastic is the resolver for the suggestTodosElastic field.
+func (r *queryResolver) SuggestTodosElastic(ctx context.Context, prefix string, limit *int) ([]string, error) {
+	var lim int64 = 5
+	if limit != nil && *limit > 0 {
+		lim = int64(*limit)
+	}
+	return r.todos().SuggestTodos(ctx, prefix, lim)
+}
+
+// Children is the resolver for the children field.
+func (r *todoElasticResolver) Children(ctx context.Context, obj *model.TodoElastic) ([]*model.TodoElastic, error) {
+	children, err := r.childTodos(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*model.TodoElastic, len(children))
+	for i := range children {
+		results[i] = todoElastic(&children[i])
+	}
+	return results, nil
+}
+
+// Progress is the resolver for the progress field.
+func (r *todoElasticResolver) Progress(ctx context.Context, obj *model.TodoElastic) (*model.TodoProgress, error) {
+	children, err := r.childTodos(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	progress := models.ProgressOf(children)
+	if progress == nil {
+		return nil, nil
+	}
+	return &model.TodoProgress{
+		Completed: int(progress.Completed),
+		Total:     int(progress.Total),
+		Ratio:     progress.Ratio,
+	}, nil
 }
 
 // Mutation returns generated.MutationResolver implementation.
@@ -139,5 +266,9 @@ func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResol
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// TodoElastic returns generated.TodoElasticResolver implementation.
+func (r *Resolver) TodoElastic() generated.TodoElasticResolver { return &todoElasticResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type todoElasticResolver struct{ *Resolver }
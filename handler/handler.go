@@ -1,19 +1,25 @@
 package handler
 
 import (
+	"context"
+	"net/http"
 	"newFeatures/cache"
 	"newFeatures/graph"
 	"newFeatures/graph/generated"
 	"newFeatures/graph/middleware"
+	"newFeatures/models"
 	"newFeatures/repository"
 	"newFeatures/service"
+	"strconv"
 
+	"github.com/99designs/gqlgen/graphql"
 	gqlhandler "github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 )
 
@@ -41,8 +47,12 @@ func NewHandler(services *service.Service, cache *cache.Cache, reg *prometheus.R
 	}
 }
 
-func (h *Handler) InitRoutes(dbType string) *gin.Engine {
+// InitRoutes mounts the route group of every enabled backend on one engine.
+func (h *Handler) InitRoutes(dbTypes []string) *gin.Engine {
 	r := gin.Default()
+	// Let the repositories read values stored in the request context, such
+	// as the owner set by parseAuthHeader, through the *gin.Context.
+	r.ContextWithFallback = true
 	metricsMiddleware := NewMetricsMiddleware(h.reg)
 	r.Use(h.CorsMiddleware, metricsMiddleware.Metrics)
 	r.GET("/metrics", prometheusHandler(h.reg))
@@ -57,88 +67,89 @@ func (h *Handler) InitRoutes(dbType string) *gin.Engine {
 	r.GET("/login", h.handleGoogleLogin)
 	r.GET("/callback", h.handleGoogleCallback)
 
-	switch dbType {
-	case repository.PostgresDB:
-		r.Use(h.parseAuthHeader, h.checkRole)
-		h.initPostgresRoutes(r)
-	case repository.MongoDB:
-		r.Use(h.protect)
-		h.initMongoRoutes(r)
-	case repository.CassandraDB:
-		h.initCassandraRoutes(r)
-	case repository.MariaDB:
-		h.initMariaRoutes(r)
-	case repository.ClickHouseDB:
-		h.initClickHouseRoutes(r)
-	case repository.CockroachDB:
-		h.initCockroachRoutes(r)
-	case repository.ElasticSearchDB:
-		h.initElasticSearchRoutes(r)
+	for _, dbType := range dbTypes {
+		// Every backend scopes its todos to the caller of the token, so no
+		// group may be reached without one.
+		group := r.Group("/"+dbType, h.setBackend(dbType), h.parseAuthHeader, h.checkRole)
+		switch dbType {
+		case repository.PostgresDB:
+			h.initUserRoutes(group)
+			r.POST("/kafka/producer", h.parseAuthHeader, h.checkRole, h.produceKafkaMessages)
+			r.POST("/rabbit/producer", h.parseAuthHeader, h.checkRole, h.produceRabbitMessages)
+		case repository.MongoDB:
+			r.GET("/kafka/consumer", h.protect, h.consumeKafkaMessages)
+			r.GET("/rabbit/consumer", h.protect, h.consumeRabbitMessages)
+		case repository.ElasticSearchDB:
+			h.initGraphQLRoutes(r)
+		}
+		h.initTodoRoutes(group)
 	}
 
 	return r
 }
 
-func (h *Handler) initPostgresRoutes(r *gin.Engine) {
-	r.PUT("/postgres/user/:id", h.updateUser)
-	r.DELETE("/postgres/user/:id", h.deleteUser)
-	r.GET("/postgres/users", h.getUsers)
-	r.GET("/postgres/user/:id", h.getUser)
-
-	r.GET("/postgres/todos", h.getTodosPostgres)
-	r.GET("/postgres/todo/:id", h.getTodoPostgres)
-	r.POST("/postgres/todo", h.createTodoPostgres)
-	r.PUT("/postgres/todo/:id", h.updateTodoPostgres)
-	r.DELETE("/postgres/todo/:id", h.deleteTodoPostgres)
-	r.POST("/kafka/producer", h.produceKafkaMessages)
-	r.POST("/rabbit/producer", h.produceRabbitMessages)
-
-}
-
-func (h *Handler) initMongoRoutes(r *gin.Engine) {
-	r.GET("/mongo/todos", h.getTodosMongo)
-	r.GET("/mongo/todo/:id", h.getTodoMongo)
-	r.POST("/mongo/todo", h.createTodoMongo)
-	r.PUT("/mongo/todo/:id", h.updateTodoMongo)
-	r.DELETE("/mongo/todo/:id", h.deleteTodoMongo)
-	r.GET("/kafka/consumer", h.consumeKafkaMessages)
-	r.GET("/rabbit/consumer", h.consumeRabbitMessages)
-
-}
-
-func (h *Handler) initCassandraRoutes(r *gin.Engine) {
-	r.GET("/cassandra/todos", h.getTodosCassandra)
-	r.GET("/cassandra/todo/:id", h.getTodoCassandra)
-	r.POST("/cassandra/todo", h.createTodoCassandra)
-	r.PUT("/cassandra/todo/:id", h.updateTodoCassandra)
-	r.DELETE("/cassandra/todo/:id", h.deleteTodoCassandra)
+func (h *Handler) initUserRoutes(r *gin.RouterGroup) {
+	r.PUT("/user/:id", h.updateUser)
+	r.DELETE("/user/:id", h.deleteUser)
+	r.GET("/users", h.getUsers)
+	r.GET("/user/:id", h.getUser)
 }
 
-func (h *Handler) initMariaRoutes(r *gin.Engine) {
-	r.GET("/maria/todos", h.getTodosMaria)
-	r.GET("/maria/todo/:id", h.getTodoMaria)
-	r.POST("/maria/todo", h.createTodoMaria)
-	r.PUT("/maria/todo/:id", h.updateTodoMaria)
-	r.DELETE("/maria/todo/:id", h.deleteTodoMaria)
+// initTodoRoutes registers the todo and list CRUD endpoints; they are
+// identical for every backend and only differ in their URL prefix. The list
+// endpoints answer 501 for the backends without lists.
+func (h *Handler) initTodoRoutes(r *gin.RouterGroup) {
+	r.GET("/todos", h.getTodos)
+	r.GET("/todos/search", h.searchTodos)
+	r.GET("/todo/:id", h.getTodo)
+	r.POST("/todo", h.createTodo)
+	r.PUT("/todo/:id", h.updateTodo)
+	r.DELETE("/todo/:id", h.deleteTodo)
+	r.PUT("/todo/:id/list", h.moveTodo)
+
+	r.GET("/lists", h.getLists)
+	r.GET("/list/:id", h.getList)
+	r.POST("/list", h.createList)
+	r.PUT("/list/:id", h.updateList)
+	r.DELETE("/list/:id", h.deleteList)
+	r.GET("/list/:id/members", h.getListMembers)
+	r.PUT("/list/:id/member/:user_id", h.updateListMember)
+	r.DELETE("/list/:id/member/:user_id", h.deleteListMember)
+	r.POST("/list/:id/invitation", h.inviteToList)
+	r.DELETE("/list/:id/invitation/:user_id", h.revokeInvitations)
+	r.POST("/invitation/accept", h.acceptInvitation)
 }
 
-func (h *Handler) initClickHouseRoutes(r *gin.Engine) {
-	r.GET("/clickhouse/todos", h.getTodosClickhouse)
-	r.GET("/clickhouse/todo/:id", h.getTodoClickhouse)
-	r.POST("/clickhouse/todo", h.createTodoClickhouse)
-	r.PUT("/clickhouse/todo/:id", h.updateTodoClickhouse)
-	r.DELETE("/clickhouse/todo/:id", h.deleteTodoClickhouse)
-}
-
-func (h *Handler) initCockroachRoutes(r *gin.Engine) {
-	r.GET("/cockroach/todos", h.getTodosCockroach)
-	r.GET("/cockroach/todo/:id", h.getTodoCockroach)
-	r.POST("/cockroach/todo", h.createTodoCockroach)
-	r.PUT("/cockroach/todo/:id", h.updateTodoCockroach)
-	r.DELETE("/cockroach/todo/:id", h.deleteTodoCockroach)
+// listParams reads the cursor and limit query parameters shared by the list
+// endpoints, replying 400 and returning false if they are invalid or if the
+// query has any other parameter than them and filters. The page parameter of
+// the offset based lists is rejected so that old clients fail loudly instead
+// of reading the first page over and over.
+func listParams(ctx *gin.Context, filters ...string) (string, int64, bool) {
+	var limit int64 = 10
+	if ctx.Query("page") != "" {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "page is not supported, pass the next_cursor of the previous page as cursor"})
+		return "", 0, false
+	}
+	for key := range ctx.Request.URL.Query() {
+		if key != "cursor" && key != "limit" && !containsString(filters, key) {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "unknown query parameter " + key})
+			return "", 0, false
+		}
+	}
+	if ctx.Query("limit") != "" {
+		paramLimit, err := strconv.ParseInt(ctx.Query("limit"), 10, 64)
+		if err != nil || paramLimit < 1 {
+			logrus.Warnf("No url request:%s", err)
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Invalid url query"})
+			return "", 0, false
+		}
+		limit = paramLimit
+	}
+	return ctx.Query("cursor"), limit, true
 }
 
-func (h *Handler) initElasticSearchRoutes(r *gin.Engine) {
+func (h *Handler) initGraphQLRoutes(r *gin.Engine) {
 	r.POST("/query", graphqlHandler(h.services, middleware.AuthMiddleware()))
 	r.GET("/", playgroundHandler())
 }
@@ -152,8 +163,14 @@ func playgroundHandler() gin.HandlerFunc {
 
 func graphqlHandler(s *service.Service, authMiddleware gin.HandlerFunc) gin.HandlerFunc {
 	h := gqlhandler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{Serv: s}}))
+	h.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(graph.WithChildLoader(ctx))
+	})
 	return func(ctx *gin.Context) {
 		authMiddleware(ctx)
+		if ctx.IsAborted() {
+			return
+		}
 		h.ServeHTTP(ctx.Writer, ctx.Request)
 	}
 }
@@ -164,3 +181,12 @@ func prometheusHandler(reg prometheus.Gatherer) gin.HandlerFunc {
 		h.ServeHTTP(c.Writer, c.Request)
 	}
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
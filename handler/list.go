@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"net/http"
+	"newFeatures/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// getLists lists the active lists, or the archived ones with archived=true.
+func (h *Handler) getLists(ctx *gin.Context) {
+	cursor, limit, ok := listParams(ctx, "archived")
+	if !ok {
+		return
+	}
+	var archived bool
+	if value := ctx.Query("archived"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "archived must be true or false"})
+			return
+		}
+		archived = parsed
+	}
+
+	page, err := h.todoService(ctx).GetLists(ctx, archived, cursor, limit)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) getList(ctx *gin.Context) {
+	list, err := h.todoService(ctx).GetList(ctx, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) createList(ctx *gin.Context) {
+	var input models.TodoList
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler createList (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+
+	id, err := h.todoService(ctx).CreateList(ctx, &input)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, id)
+}
+
+// updateList replaces the name and the archived flag of a list.
+func (h *Handler) updateList(ctx *gin.Context) {
+	var input models.TodoList
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler updateList (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	input.ID = ctx.Param("id")
+	if err := h.todoService(ctx).UpdateList(ctx, &input); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "List updated successfully"})
+}
+
+// deleteList deletes a list together with its todos.
+func (h *Handler) deleteList(ctx *gin.Context) {
+	todoIDs, err := h.todoService(ctx).DeleteList(ctx, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	h.evictTodos(ctx, todoIDs)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "List deleted successfully"})
+}
+
+// moveTodoInput names the list a todo moves to; an empty list_id takes the
+// todo out of its list.
+type moveTodoInput struct {
+	ListID *string `json:"list_id" binding:"required"`
+}
+
+func (h *Handler) moveTodo(ctx *gin.Context) {
+	var input moveTodoInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler moveTodo (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	id := ctx.Param("id")
+	subtasks, err := h.todoService(ctx).MoveTodo(ctx, id, *input.ListID)
+	h.evictTodos(ctx, subtasks)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if err := h.cache.Delete(ctx, todoCacheKey(ctx, id)); err != nil {
+		logrus.Errorf("Handler moveTodo (cache delete): %s", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Todo moved successfully"})
+}
+
+// getListMembers lists who has access to a list.
+func (h *Handler) getListMembers(ctx *gin.Context) {
+	members, err := h.todoService(ctx).ListMembers(ctx, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, members)
+}
+
+type listRoleInput struct {
+	Role models.ListRole `json:"role" binding:"required"`
+}
+
+func (h *Handler) updateListMember(ctx *gin.Context) {
+	var input listRoleInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler updateListMember (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	if err := h.todoService(ctx).SetListMemberRole(ctx, ctx.Param("id"), ctx.Param("user_id"), input.Role); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// deleteListMember stops sharing a list with a user; members may remove
+// themselves to leave a list.
+func (h *Handler) deleteListMember(ctx *gin.Context) {
+	if err := h.todoService(ctx).RemoveListMember(ctx, ctx.Param("id"), ctx.Param("user_id")); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+type invitationInput struct {
+	Email string          `json:"email" binding:"required"`
+	Role  models.ListRole `json:"role" binding:"required"`
+}
+
+// inviteToList mails an invitation to a registered user. The invitation
+// takes effect once the user accepts it, so the reply is 202.
+func (h *Handler) inviteToList(ctx *gin.Context) {
+	var input invitationInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler inviteToList (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	if err := h.todoService(ctx).InviteToList(ctx, ctx.Param("id"), input.Email, input.Role); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Invitation sent"})
+}
+
+// revokeInvitations revokes the invitations to a list that a user has not
+// accepted yet.
+func (h *Handler) revokeInvitations(ctx *gin.Context) {
+	if err := h.todoService(ctx).RevokeInvitations(ctx, ctx.Param("id"), ctx.Param("user_id")); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Invitations revoked"})
+}
+
+type acceptInvitationInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// acceptInvitation shares the list of an invitation with the caller, who
+// must be the invited user, and replies with the list.
+func (h *Handler) acceptInvitation(ctx *gin.Context) {
+	var input acceptInvitationInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler acceptInvitation (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	list, err := h.todoService(ctx).AcceptInvitation(ctx, input.Token)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, list)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"newFeatures/models"
+	"newFeatures/service"
 	"strings"
 	"time"
 
@@ -51,6 +52,7 @@ func (h *Handler) parseAuthHeader(ctx *gin.Context) {
 	}
 	ctx.Set("role", role)
 	ctx.Set("id", id)
+	ctx.Request = ctx.Request.WithContext(service.WithCaller(ctx.Request.Context(), id, role))
 }
 
 func (h *Handler) checkRole(ctx *gin.Context) {
@@ -61,6 +63,19 @@ func (h *Handler) checkRole(ctx *gin.Context) {
 	}
 }
 
+const backendCtx = "backend"
+
+// setBackend records which backend's route group is serving the request.
+func (h *Handler) setBackend(dbType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(backendCtx, dbType)
+	}
+}
+
+func (h *Handler) todoService(ctx *gin.Context) service.Todo {
+	return h.services.Backend(ctx.GetString(backendCtx))
+}
+
 type MetricsMiddleware struct {
 	OpsProcessed          *prometheus.CounterVec
 	ReqDuration           *prometheus.HistogramVec
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"newFeatures/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+func (h *Handler) produceKafkaMessages(ctx *gin.Context) {
+	var input models.Todo
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("binding JSON: %s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+
+	jsonData, err := json.Marshal(input)
+	if err != nil {
+		logrus.Errorf("failed to marshal JSON: %v", err)
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	msg := kafka.Message{
+		Value: jsonData,
+	}
+
+	err = h.kafkaWriter.WriteMessages(ctx, msg)
+	if err != nil {
+		logrus.Errorf("failed to produce message: %v", err)
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Todo sent successfully"})
+}
+
+func (h *Handler) produceRabbitMessages(ctx *gin.Context) {
+	var input models.Todo
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+
+	jsonData, err := json.Marshal(input)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	err = h.rabbitChan.Publish(
+		"todo_exchange",
+		"todo.key1",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        jsonData,
+		},
+	)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Todo sent successfully"})
+}
+
+func (h *Handler) consumeKafkaMessages(ctx *gin.Context) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	msg, err := h.kafkaReader.ReadMessage(timeoutCtx)
+	if err != nil {
+		if err == io.EOF {
+			ctx.JSON(http.StatusOK, gin.H{"message": "No messages in Kafka queue"})
+			return
+		}
+		logrus.Errorf("failed to read message: %v", err)
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	var todo models.Todo
+	if err := json.Unmarshal(msg.Value, &todo); err != nil {
+		logrus.Errorf("failed to unmarshal message: %v", err)
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": todo})
+}
+
+func (h *Handler) consumeRabbitMessages(ctx *gin.Context) {
+	messages, err := h.rabbitChan.Consume(
+		"todo_queue",
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "internal server error"})
+		return
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			ctx.JSON(http.StatusOK, gin.H{"message": "No messages in RabbitMQ queue"})
+			return
+		case message := <-messages:
+			var todo models.Todo
+			err := json.Unmarshal(message.Body, &todo)
+			if err != nil {
+				continue
+			}
+			ctx.JSON(http.StatusOK, gin.H{"message": todo})
+			return
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"newFeatures/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func todoCacheKey(ctx *gin.Context, id string) string {
+	return fmt.Sprintf("todo:%s:%s", ctx.GetString(backendCtx), id)
+}
+
+// todoErrorStatus maps service and repository errors to an HTTP status code.
+func todoErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrTodoNotFound),
+		errors.Is(err, repository.ErrListNotFound),
+		errors.Is(err, repository.ErrListMemberNotFound),
+		errors.Is(err, service.ErrParentNotFound),
+		errors.Is(err, service.ErrorEmailDoesNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrTodoForbidden),
+		errors.Is(err, service.ErrListForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrTodoConflict),
+		errors.Is(err, repository.ErrListArchived),
+		errors.Is(err, repository.ErrLastListOwner),
+		errors.Is(err, service.ErrSubtaskMove):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrInvalidTodoID),
+		errors.Is(err, repository.ErrInvalidListID),
+		errors.Is(err, repository.ErrInvalidParent),
+		errors.Is(err, repository.ErrInvalidCursor),
+		errors.Is(err, service.ErrInvalidPagination),
+		errors.Is(err, service.ErrInvalidSort),
+		errors.Is(err, service.ErrInvalidFilter),
+		errors.Is(err, service.ErrInvalidPriority),
+		errors.Is(err, service.ErrLongDescription),
+		errors.Is(err, service.ErrEmptyTitle),
+		errors.Is(err, service.ErrEmptyListName),
+		errors.Is(err, service.ErrLongListName),
+		errors.Is(err, service.ErrInvalidListRole),
+		errors.Is(err, service.ErrInvalidInvitation),
+		errors.Is(err, service.ErrTodoTooDeep),
+		errors.Is(err, service.ErrParentListMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrSearchNotSupported),
+		errors.Is(err, service.ErrBulkNotSupported),
+		errors.Is(err, service.ErrListsNotSupported),
+		errors.Is(err, service.ErrSubtasksNotSupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// expandParam reads the expand query parameter, replying 400 and returning
+// false for any other value than children.
+func expandParam(ctx *gin.Context) (children bool, ok bool) {
+	switch ctx.Query("expand") {
+	case "":
+		return false, true
+	case "children":
+		return true, true
+	default:
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "expand must be children"})
+		return false, false
+	}
+}
+
+// getTodo replies with the subtasks of the todo and its progress when
+// expand=children; the cache only holds the todo itself.
+func (h *Handler) getTodo(ctx *gin.Context) {
+	id := ctx.Param("id")
+	children, ok := expandParam(ctx)
+	if !ok {
+		return
+	}
+	if children {
+		h.getTodoTree(ctx, id)
+		return
+	}
+
+	cacheKey := todoCacheKey(ctx, id)
+	todo, err := h.cache.Get(ctx, cacheKey)
+	if err != nil {
+		logrus.Errorf("Handler getTodo (cache get): %s", err)
+	}
+
+	if todo != "" {
+		var t models.Todo
+		err := json.Unmarshal([]byte(todo), &t)
+		if err != nil {
+			logrus.Errorf("Handler getTodo (unmarshaling todo): %s", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to get todo"})
+			return
+		}
+		// The cache is shared by all users; the todos of others and the
+		// todos in lists, which are shared, are left to the service.
+		if t.ListID == "" && repository.InOwnerScope(ctx, t.OwnerID) {
+			ctx.JSON(http.StatusOK, t)
+			return
+		}
+	}
+
+	t, err := h.todoService(ctx).GetTodo(ctx, id)
+	if err != nil {
+		logrus.Errorf("Handler getTodo (db get): %s", err)
+		ctx.AbortWithStatusJSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	jsonTodo, err := json.Marshal(t)
+	if err != nil {
+		logrus.Errorf("Handler getTodo (marshaling todo): %s", err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed to get todo"})
+		return
+	}
+
+	if err := h.cache.Set(ctx, cacheKey, string(jsonTodo)); err != nil {
+		logrus.Errorf("Handler getTodo (cache set): %s", err)
+	}
+
+	ctx.JSON(http.StatusOK, t)
+}
+
+// getTodoTree replies with a todo together with its subtasks and progress.
+func (h *Handler) getTodoTree(ctx *gin.Context, id string) {
+	t, err := h.todoService(ctx).GetTodo(ctx, id)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	todos := []models.Todo{*t}
+	if err := h.todoService(ctx).ExpandChildren(ctx, todos); err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, todos[0])
+}
+
+func (h *Handler) getTodos(ctx *gin.Context) {
+	cursor, limit, ok := listParams(ctx, "list_id", "done", "q", "sort", "created_from", "created_to", "due_from", "due_to", "expand")
+	if !ok {
+		return
+	}
+	children, ok := expandParam(ctx)
+	if !ok {
+		return
+	}
+	query := models.TodoQuery{ListID: ctx.Query("list_id"), Title: ctx.Query("q"), Limit: limit}
+	for param, bound := range map[string]**time.Time{
+		"created_from": &query.Created.From,
+		"created_to":   &query.Created.To,
+		"due_from":     &query.Due.From,
+		"due_to":       &query.Due.To,
+	} {
+		if value := ctx.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: param + " must be an RFC 3339 time"})
+				return
+			}
+			*bound = &t
+		}
+	}
+	if done := ctx.Query("done"); done != "" {
+		parsed, err := strconv.ParseBool(done)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "done must be true or false"})
+			return
+		}
+		query.Done = &parsed
+	}
+	sort, err := service.ParseTodoSort(ctx.Query("sort"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	query.Sort = sort
+
+	page, err := h.todoService(ctx).ListTodos(ctx, query, cursor)
+	if err == nil && children {
+		err = h.todoService(ctx).ExpandChildren(ctx, page.Items)
+	}
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+// searchTodos answers 501 for the backends without search. A page resumes
+// after the cursor of the last hit of the previous one.
+func (h *Handler) searchTodos(ctx *gin.Context) {
+	cursor, limit, ok := listParams(ctx, "q")
+	if !ok {
+		return
+	}
+	result, err := h.todoService(ctx).SearchTodosAfter(ctx, ctx.Query("q"), cursor, limit)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) createTodo(ctx *gin.Context) {
+	var input models.Todo
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler createTodo (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+
+	id, err := h.todoService(ctx).CreateTodo(ctx, &input)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, id)
+}
+
+// updateTodo also completes all subtasks of a completed todo when
+// complete_descendants=true.
+func (h *Handler) updateTodo(ctx *gin.Context) {
+	var completeDescendants bool
+	if value := ctx.Query("complete_descendants"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "complete_descendants must be true or false"})
+			return
+		}
+		completeDescendants = parsed
+	}
+	var input models.Todo
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logrus.Warnf("Handler updateTodo (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	input.ID = ctx.Param("id")
+	err := h.todoService(ctx).UpdateTodo(ctx, &input)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// The input lacks created_at, so the next read caches the stored todo.
+	if err := h.cache.Delete(ctx, todoCacheKey(ctx, input.ID)); err != nil {
+		logrus.Errorf("Handler updateTodo (cache delete): %s", err)
+	}
+
+	if completeDescendants && input.Done {
+		completed, err := h.todoService(ctx).CompleteDescendants(ctx, input.ID)
+		h.evictTodos(ctx, completed)
+		if err != nil {
+			ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Todo updated successfully"})
+}
+
+func (h *Handler) deleteTodo(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	subtasks, err := h.todoService(ctx).DeleteTodoByID(ctx, id)
+	h.evictTodos(ctx, subtasks)
+	if err != nil {
+		ctx.JSON(todoErrorStatus(err), models.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if err := h.cache.Delete(ctx, todoCacheKey(ctx, id)); err != nil {
+		logrus.Errorf("Handler deleteTodo (cache delete): %s", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Todo deleted successfully"})
+}
+
+// evictTodos removes todos changed along with another one, such as its
+// subtasks, from the cache.
+func (h *Handler) evictTodos(ctx *gin.Context, ids []string) {
+	for _, id := range ids {
+		if err := h.cache.Delete(ctx, todoCacheKey(ctx, id)); err != nil {
+			logrus.Errorf("Handler (cache delete): %s", err)
+		}
+	}
+}
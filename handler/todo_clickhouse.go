@@ -1,94 +0,0 @@
-package handler
-
-import (
-	"net/http"
-	"newFeatures/models"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-)
-
-func (h *Handler) getTodosClickhouse(ctx *gin.Context) {
-	pageStr := ctx.DefaultQuery("page", "1")
-	limitStr := ctx.DefaultQuery("limit", "10")
-
-	page, err := strconv.ParseInt(pageStr, 10, 64)
-	if err != nil || page <= 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid page value"})
-		return
-	}
-
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
-	if err != nil || limit <= 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value"})
-		return
-	}
-
-	todos, err := h.services.TodoClickHouseService.GetTodos(ctx, page, limit)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, todos)
-}
-
-func (h *Handler) getTodoClickhouse(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, err.Error())
-	}
-	todo, err := h.services.TodoClickHouseService.GetTodoByID(ctx, id)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	ctx.JSON(http.StatusOK, todo)
-}
-
-func (h *Handler) createTodoClickhouse(ctx *gin.Context) {
-	var todo models.TodoClickHouse
-	if err := ctx.ShouldBindJSON(&todo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	err := h.services.TodoClickHouseService.CreateTodo(ctx, &todo)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	ctx.JSON(http.StatusCreated, gin.H{"message": "Todo created successfully"})
-}
-
-func (h *Handler) updateTodoClickhouse(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, err.Error())
-	}
-	var todo models.TodoClickHouse
-	if err := ctx.ShouldBindJSON(&todo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	todo.ID = id
-	err = h.services.TodoClickHouseService.UpdateTodo(ctx, &todo)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	ctx.JSON(http.StatusOK, gin.H{"message": "Todo updated successfully"})
-}
-
-func (h *Handler) deleteTodoClickhouse(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, err.Error())
-	}
-	err = h.services.TodoClickHouseService.DeleteTodo(ctx, id)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	ctx.JSON(http.StatusOK, gin.H{"message": "Todo deleted successfully"})
-}
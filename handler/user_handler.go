@@ -59,32 +59,13 @@ func (h *Handler) authUser(ctx *gin.Context) {
 }
 
 func (h *Handler) getUsers(ctx *gin.Context) {
-	var page int64 = 1
-	var limit int64 = 10
-
-	if pageStr := ctx.Query("page"); pageStr != "" {
-		paramPage, err := strconv.ParseInt(pageStr, 10, 64)
-		if err != nil || paramPage < 0 {
-			logrus.Warnf("Invalid page parameter: %s", err)
-			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Invalid url query"})
-			return
-		}
-		page = paramPage
-	}
-
-	if limitStr := ctx.Query("limit"); limitStr != "" {
-		paramLimit, err := strconv.ParseInt(limitStr, 10, 64)
-		if err != nil || paramLimit < 0 {
-			logrus.Warnf("Invalid limit parameter: %s", err)
-			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{Message: "Invalid url query"})
-			return
-		}
-		limit = paramLimit
+	cursor, limit, ok := listParams(ctx)
+	if !ok {
+		return
 	}
-
-	users, err := h.services.Authorization.Users(ctx, page, limit)
+	users, err := h.services.Authorization.Users(ctx, cursor, limit)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		ctx.AbortWithStatusJSON(todoErrorStatus(err), gin.H{"message": err.Error()})
 		return
 	}
 
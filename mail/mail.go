@@ -16,14 +16,23 @@ const (
 )
 
 func SendEmail(post *models.Post) {
+	send(post.Email, fmt.Sprintf("Dear client, your current password is: %s.", post.Password))
+}
+
+// SendInvitation mails an invitation to a shared list. The token is accepted
+// with POST /<db>/invitation/accept.
+func SendInvitation(invitation *models.Invitation) {
+	send(invitation.Email, fmt.Sprintf("Dear client, you are invited to the list %q as %s. "+
+		"To accept the invitation, log in and send this token: %s", invitation.ListName, invitation.Role, invitation.Token))
+}
+
+func send(to, msg string) {
 	auth := smtp.PlainAuth("", os.Getenv("POST_FROM"), os.Getenv("POST_PASSWORD"), Host)
 
 	from := os.Getenv("POST_FROM")
-	to := post.Email
 	smtpHost := Host
 	smtpPort := Port
 
-	msg := fmt.Sprintf("Dear client, your current password is: %s.", post.Password)
 	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, to, Subject, msg)
 
 	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(message))
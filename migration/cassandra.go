@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+type cassandraDriver struct {
+	session *gocql.Session
+}
+
+func (d *cassandraDriver) init(ctx context.Context) error {
+	return d.session.Query(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version int PRIMARY KEY,
+			name text,
+			applied_at timestamp
+		)`).WithContext(ctx).Exec()
+}
+
+func (d *cassandraDriver) applied(ctx context.Context) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	iter := d.session.Query("SELECT version, applied_at FROM schema_migrations").WithContext(ctx).Iter()
+	var version int
+	var at time.Time
+	for iter.Scan(&version, &at) {
+		applied[version] = at
+	}
+	return applied, iter.Close()
+}
+
+// apply runs the statements one by one; Cassandra has no transactions, so a
+// failed migration has to be fixed by hand before it can be retried.
+func (d *cassandraDriver) apply(ctx context.Context, m Migration, up bool) error {
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	for _, stmt := range statements(script) {
+		if err := d.session.Query(stmt).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		return d.session.Query("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.Version, m.Name, time.Now().UTC()).WithContext(ctx).Exec()
+	}
+	return d.session.Query("DELETE FROM schema_migrations WHERE version = ?", m.Version).WithContext(ctx).Exec()
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id UUID,
+	title String,
+	done UInt8
+) ENGINE = MergeTree
+ORDER BY id;
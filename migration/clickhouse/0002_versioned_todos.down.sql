@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS todos_plain (
+	id UUID,
+	title String,
+	done UInt8
+) ENGINE = MergeTree
+ORDER BY id;
+
+INSERT INTO todos_plain (id, title, done)
+SELECT id, title, done FROM todos FINAL WHERE deleted = 0;
+
+DROP TABLE todos;
+
+RENAME TABLE todos_plain TO todos;
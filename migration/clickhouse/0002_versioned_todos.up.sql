@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS todos_versioned (
+	id UUID,
+	title String,
+	done UInt8,
+	version UInt64,
+	deleted UInt8 DEFAULT 0
+) ENGINE = ReplacingMergeTree(version)
+ORDER BY id;
+
+INSERT INTO todos_versioned (id, title, done, version, deleted)
+SELECT id, title, done, 1, 0 FROM todos;
+
+DROP TABLE todos;
+
+RENAME TABLE todos_versioned TO todos;
@@ -0,0 +1,6 @@
+ALTER TABLE todos
+	DROP COLUMN IF EXISTS updated_at,
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS priority,
+	DROP COLUMN IF EXISTS due_at,
+	DROP COLUMN IF EXISTS description;
@@ -0,0 +1,10 @@
+-- The rows that exist already read the epoch as their timestamps until the
+-- mutation below has stamped them with the time of the migration.
+ALTER TABLE todos
+	ADD COLUMN IF NOT EXISTS description String DEFAULT '',
+	ADD COLUMN IF NOT EXISTS due_at Nullable(DateTime64(3, 'UTC')),
+	ADD COLUMN IF NOT EXISTS priority UInt8 DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS created_at DateTime64(3, 'UTC') DEFAULT toDateTime64(0, 3, 'UTC'),
+	ADD COLUMN IF NOT EXISTS updated_at DateTime64(3, 'UTC') DEFAULT toDateTime64(0, 3, 'UTC');
+
+ALTER TABLE todos UPDATE created_at = now64(3), updated_at = now64(3) WHERE created_at = toDateTime64(0, 3, 'UTC');
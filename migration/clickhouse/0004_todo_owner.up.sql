@@ -0,0 +1,3 @@
+-- Todos that exist already belong to the public owner, which every client
+-- that is not logged in acts as.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS owner_id String DEFAULT 'public';
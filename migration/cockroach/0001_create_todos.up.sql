@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	title VARCHAR(225) NOT NULL,
+	completed BOOL DEFAULT FALSE
+);
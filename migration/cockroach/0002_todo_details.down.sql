@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS todos@todos_due_at_idx;
+DROP INDEX IF EXISTS todos@todos_created_at_idx;
+ALTER TABLE todos
+	DROP COLUMN IF EXISTS updated_at,
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS priority,
+	DROP COLUMN IF EXISTS due_at,
+	DROP COLUMN IF EXISTS description;
@@ -0,0 +1,10 @@
+-- Rows that exist already are stamped with the time of the migration.
+ALTER TABLE todos
+	ADD COLUMN IF NOT EXISTS description STRING NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS priority INT2 NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3),
+	ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS todos_created_at_idx ON todos (created_at, id);
+CREATE INDEX IF NOT EXISTS todos_due_at_idx ON todos (due_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos@todos_owner_created_at_idx;
+DROP INDEX IF EXISTS todos@todos_owner_id_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	title VARCHAR(225) NOT NULL,
+	completed BOOLEAN DEFAULT FALSE
+);
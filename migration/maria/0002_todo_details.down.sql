@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS todos_due_at_idx ON todos;
+DROP INDEX IF EXISTS todos_created_at_idx ON todos;
+ALTER TABLE todos
+	DROP COLUMN IF EXISTS updated_at,
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS priority,
+	DROP COLUMN IF EXISTS due_at,
+	DROP COLUMN IF EXISTS description;
@@ -0,0 +1,11 @@
+-- Rows that exist already are stamped with the time of the migration. The
+-- driver writes times in UTC, which the server clock is assumed to be in.
+ALTER TABLE todos
+	ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS due_at DATETIME(3) NULL,
+	ADD COLUMN IF NOT EXISTS priority TINYINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+	ADD COLUMN IF NOT EXISTS updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3);
+
+CREATE INDEX IF NOT EXISTS todos_created_at_idx ON todos (created_at, id);
+CREATE INDEX IF NOT EXISTS todos_due_at_idx ON todos (due_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_owner_created_at_idx ON todos;
+DROP INDEX IF EXISTS todos_owner_id_idx ON todos;
+ALTER TABLE todos DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,261 @@
+// Package migration applies the numbered schema migrations of every backend
+// and records which of them ran in a bookkeeping table or collection.
+//
+// Migrations live in a directory named after the backend, one file per
+// direction: 0001_create_todos.up.sql and 0001_create_todos.down.sql. SQL and
+// CQL files may hold several statements separated by semicolons; Mongo files
+// hold a JSON array of database commands, in which {{collection}} stands for
+// the configured todo collection.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//go:embed postgres sqlite maria cockroach clickhouse cassandra mongo
+var files embed.FS
+
+// Table is the name of the bookkeeping table or collection.
+const Table = "schema_migrations"
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// driver runs migration scripts against one backend and keeps its bookkeeping.
+type driver interface {
+	init(ctx context.Context) error
+	applied(ctx context.Context) (map[int]time.Time, error)
+	apply(ctx context.Context, m Migration, up bool) error
+}
+
+type Migrator struct {
+	dbType     string
+	driver     driver
+	migrations []Migration
+}
+
+// New returns a Migrator for the backend named dbType. db is the connection
+// returned by database.Open.
+func New(dbType string, db interface{}) (*Migrator, error) {
+	d, err := newDriver(dbType, db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := load(dbType)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{dbType: dbType, driver: d, migrations: migrations}, nil
+}
+
+// Supported reports whether dbType has schema migrations. Elasticsearch and
+// the in-memory backend have none.
+func Supported(dbType string) bool {
+	_, err := fs.Stat(files, dbType)
+	return err == nil
+}
+
+func newDriver(dbType string, db interface{}) (driver, error) {
+	switch dbType {
+	case "postgres", "cockroach", "sqlite", "maria", "clickhouse":
+		sqlDB, ok := db.(*sql.DB)
+		if !ok {
+			return nil, fmt.Errorf("invalid database %s connection", dbType)
+		}
+		dialects := map[string]sqlDialect{
+			"postgres":   postgresDialect,
+			"cockroach":  cockroachDialect,
+			"sqlite":     sqliteDialect,
+			"maria":      mariaDialect,
+			"clickhouse": clickHouseDialect,
+		}
+		return &sqlDriver{db: sqlDB, dialect: dialects[dbType]}, nil
+	case "cassandra":
+		session, ok := db.(*gocql.Session)
+		if !ok {
+			return nil, errors.New("invalid database cassandra connection")
+		}
+		return &cassandraDriver{session: session}, nil
+	case "mongo":
+		collection, ok := db.(*mongo.Collection)
+		if !ok {
+			return nil, errors.New("invalid database mongo connection")
+		}
+		return &mongoDriver{db: collection.Database(), collection: collection.Name()}, nil
+	default:
+		return nil, fmt.Errorf("%s has no schema migrations", dbType)
+	}
+}
+
+// load reads the migrations of dbType sorted by version. Every version needs
+// both an up and a down script.
+func load(dbType string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dbType, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		// 0001_create_todos.up.sql -> "0001_create_todos", "up"
+		base := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s/%s: file name must look like 0001_name.up.ext", dbType, entry.Name())
+		}
+
+		data, err := fs.ReadFile(files, path.Join(dbType, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s/%04d has two names: %s and %s", dbType, version, m.Name, name)
+		}
+		switch direction {
+		case ".up":
+			m.Up = string(data)
+		case ".down":
+			m.Down = string(data)
+		default:
+			return nil, fmt.Errorf("migration %s/%s: direction must be up or down", dbType, entry.Name())
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s/%04d_%s needs both an up and a down script", dbType, m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns the ones it ran.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.driver.apply(ctx, migration, true); err != nil {
+			return ran, fmt.Errorf("%s migration %04d_%s up: %w", m.dbType, migration.Version, migration.Name, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.driver.apply(ctx, migration, false); err != nil {
+			return reverted, fmt.Errorf("%s migration %04d_%s down: %w", m.dbType, migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// Status lists every known migration with the time it was applied, if it was.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	if err := m.driver.init(ctx); err != nil {
+		return nil, fmt.Errorf("creating %s %s: %w", m.dbType, Table, err)
+	}
+	applied, err := m.driver.applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s %s: %w", m.dbType, Table, err)
+	}
+	return applied, nil
+}
+
+// statements splits a script on semicolons for drivers that execute one
+// statement per call. Semicolons inside $$-quoted bodies, as used by
+// Postgres functions, are kept; scripts must not use them inside other
+// literals.
+func statements(script string) []string {
+	var stmts []string
+	add := func(stmt string) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	quoted := false
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch {
+		case strings.HasPrefix(script[i:], "$$"):
+			quoted = !quoted
+			i++
+		case script[i] == ';' && !quoted:
+			add(script[start:i])
+			start = i + 1
+		}
+	}
+	add(script[start:])
+	return stmts
+}
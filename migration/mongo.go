@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error codes that make create and drop idempotent, like IF NOT EXISTS and
+// IF EXISTS in the SQL migrations.
+const (
+	mongoNamespaceNotFound = 26
+	mongoNamespaceExists   = 48
+)
+
+type mongoDriver struct {
+	db         *mongo.Database
+	collection string
+}
+
+type mongoMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// init is a no-op: the collection is created by the first insert.
+func (d *mongoDriver) init(ctx context.Context) error {
+	return nil
+}
+
+func (d *mongoDriver) applied(ctx context.Context) (map[int]time.Time, error) {
+	cur, err := d.db.Collection(Table).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	applied := make(map[int]time.Time)
+	for cur.Next(ctx) {
+		var m mongoMigration
+		if err := cur.Decode(&m); err != nil {
+			return nil, err
+		}
+		applied[m.Version] = m.AppliedAt
+	}
+	return applied, cur.Err()
+}
+
+// apply runs every command of the script with RunCommand. Mongo has no
+// transactions for DDL, so a failed migration has to be fixed by hand.
+func (d *mongoDriver) apply(ctx context.Context, m Migration, up bool) error {
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	script = strings.ReplaceAll(script, "{{collection}}", d.collection)
+	var parsed struct {
+		Commands []bson.D `bson:"commands"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(`{"commands":`+script+`}`), false, &parsed); err != nil {
+		return fmt.Errorf("parsing commands: %w", err)
+	}
+	for _, command := range parsed.Commands {
+		err := d.db.RunCommand(ctx, command).Err()
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && (commandErr.Code == mongoNamespaceExists || commandErr.Code == mongoNamespaceNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	collection := d.db.Collection(Table)
+	if up {
+		_, err := collection.InsertOne(ctx, mongoMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()})
+		return err
+	}
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": m.Version})
+	return err
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id serial not null primary key,
+	title varchar(225) NOT NULL,
+	done bool DEFAULT FALSE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id serial not null primary key,
+    name varchar(225) not null,
+    email varchar(225) not null UNIQUE,
+    phone varchar(225) not null UNIQUE,
+    password varchar(225) not null,
+    role varchar(225) not null default 'USER',
+    CONSTRAINT proper_email CHECK (email ~* '^[A-Za-z0-9._+%-]+@[A-Za-z0-9.-]+[.][A-Za-z]+$')
+);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS todos_record_change ON todos;
+DROP FUNCTION IF EXISTS record_todo_change();
+DROP TABLE IF EXISTS todo_changes;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS todo_changes (
+	seq bigserial PRIMARY KEY,
+	todo_id integer NOT NULL,
+	changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION record_todo_change() RETURNS trigger AS $$
+DECLARE
+	changed_id integer;
+	change_seq bigint;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		changed_id := OLD.id;
+	ELSE
+		changed_id := NEW.id;
+	END IF;
+	INSERT INTO todo_changes (todo_id) VALUES (changed_id) RETURNING seq INTO change_seq;
+	PERFORM pg_notify('todo_changes', change_seq::text);
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS todos_record_change ON todos;
+CREATE TRIGGER todos_record_change
+	AFTER INSERT OR UPDATE OR DELETE ON todos
+	FOR EACH ROW EXECUTE PROCEDURE record_todo_change();
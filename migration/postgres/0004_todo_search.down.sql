@@ -0,0 +1,4 @@
+-- pg_trgm is left installed, other schemas in the database may use it.
+DROP INDEX IF EXISTS todos_title_trgm_idx;
+DROP INDEX IF EXISTS todos_search_idx;
+ALTER TABLE todos DROP COLUMN IF EXISTS search;
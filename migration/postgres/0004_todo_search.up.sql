@@ -0,0 +1,10 @@
+-- Full-text search needs Postgres 12 for the generated column. The trigram
+-- index backs the fuzzy fallback for misspelled queries and the title
+-- suggestions.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS search tsvector
+	GENERATED ALWAYS AS (to_tsvector('english', title)) STORED;
+
+CREATE INDEX IF NOT EXISTS todos_search_idx ON todos USING GIN (search);
+CREATE INDEX IF NOT EXISTS todos_title_trgm_idx ON todos USING GIN (title gin_trgm_ops);
@@ -0,0 +1,16 @@
+-- Lists group the todos of one owner. Todos that exist already are in none.
+CREATE TABLE IF NOT EXISTS todo_lists
+(
+    id serial not null primary key,
+    owner_id text NOT NULL,
+    name varchar(225) NOT NULL CHECK (name <> ''),
+    archived boolean NOT NULL DEFAULT FALSE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS todo_lists_owner_id_idx ON todo_lists (owner_id, archived, id);
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS list_id integer REFERENCES todo_lists (id);
+
+CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id, id);
@@ -0,0 +1,16 @@
+-- The users a list is shared with. The user who created a list is its first
+-- owner, including for the lists that exist already.
+CREATE TABLE IF NOT EXISTS list_members
+(
+    list_id integer NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+    user_id text NOT NULL,
+    role varchar(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS list_members_user_id_idx ON list_members (user_id, list_id);
+
+INSERT INTO list_members (list_id, user_id, role, created_at)
+SELECT id, owner_id, 'owner', created_at FROM todo_lists
+ON CONFLICT DO NOTHING;
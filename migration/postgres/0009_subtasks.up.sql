@@ -0,0 +1,4 @@
+-- Subtasks point at their parent todo and go with it when it is deleted.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS parent_id integer REFERENCES todos (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id, id);
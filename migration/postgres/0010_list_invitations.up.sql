@@ -0,0 +1,14 @@
+-- Invitations to share a list. The token mailed to the invited user names
+-- its row, so it is accepted at most once and stops working when revoked.
+CREATE TABLE IF NOT EXISTS list_invitations
+(
+    id text not null primary key,
+    list_id integer NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+    user_id text NOT NULL,
+    role varchar(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    accepted_at timestamptz,
+    revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS list_invitations_user_id_idx ON list_invitations (list_id, user_id);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS todos_record_change ON todos;
+CREATE TRIGGER todos_record_change
+	AFTER INSERT OR UPDATE OR DELETE ON todos
+	FOR EACH ROW EXECUTE PROCEDURE record_todo_change();
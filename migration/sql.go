@@ -0,0 +1,169 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type sqlDialect struct {
+	createTable string
+	// selectApplied returns the version and applied_at of applied migrations.
+	selectApplied string
+	// record returns the bookkeeping statement for applying (up) or
+	// reverting a migration.
+	record func(m Migration, up bool, at time.Time) (string, []interface{})
+	// transactional dialects run a migration and its bookkeeping in one
+	// transaction. The others commit every DDL statement implicitly.
+	transactional bool
+}
+
+func recordWith(insert, delete string) func(Migration, bool, time.Time) (string, []interface{}) {
+	return func(m Migration, up bool, at time.Time) (string, []interface{}) {
+		if up {
+			return insert, []interface{}{m.Version, m.Name, at}
+		}
+		return delete, []interface{}{m.Version}
+	}
+}
+
+var postgresDialect = sqlDialect{
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(225) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`,
+	selectApplied: "SELECT version, applied_at FROM schema_migrations",
+	record: recordWith(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+		"DELETE FROM schema_migrations WHERE version = $1",
+	),
+	transactional: true,
+}
+
+// CockroachDB rejects some schema changes that share a transaction with
+// writes, so its migrations are not wrapped in one.
+var cockroachDialect = sqlDialect{
+	createTable:   postgresDialect.createTable,
+	selectApplied: postgresDialect.selectApplied,
+	record:        postgresDialect.record,
+}
+
+var sqliteDialect = sqlDialect{
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(225) NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`,
+	selectApplied: "SELECT version, applied_at FROM schema_migrations",
+	record: recordWith(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		"DELETE FROM schema_migrations WHERE version = ?",
+	),
+	transactional: true,
+}
+
+var mariaDialect = sqlDialect{
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(225) NOT NULL,
+			applied_at DATETIME(6) NOT NULL
+		)`,
+	selectApplied: sqliteDialect.selectApplied,
+	record:        sqliteDialect.record,
+}
+
+// ClickHouse has no cheap deletes, so its bookkeeping is append-only: every
+// up and down adds a row and the latest row of a version decides its state.
+var clickHouseDialect = sqlDialect{
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version UInt32,
+			name String,
+			applied UInt8,
+			seq UInt64,
+			applied_at DateTime
+		) ENGINE = MergeTree
+		ORDER BY (version, seq)`,
+	selectApplied: `
+		SELECT version, argMax(applied_at, seq)
+		FROM schema_migrations
+		GROUP BY version
+		HAVING argMax(applied, seq) = 1`,
+	record: func(m Migration, up bool, at time.Time) (string, []interface{}) {
+		var applied uint8
+		if up {
+			applied = 1
+		}
+		return "INSERT INTO schema_migrations (version, name, applied, seq, applied_at) VALUES (?, ?, ?, ?, ?)",
+			[]interface{}{uint32(m.Version), m.Name, applied, uint64(at.UnixNano()), at}
+	},
+}
+
+type sqlDriver struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func (d *sqlDriver) init(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, d.dialect.createTable)
+	return err
+}
+
+func (d *sqlDriver) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := d.db.QueryContext(ctx, d.dialect.selectApplied)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[int(version)] = at
+	}
+	return applied, rows.Err()
+}
+
+func (d *sqlDriver) apply(ctx context.Context, m Migration, up bool) error {
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+
+	if !d.dialect.transactional {
+		for _, stmt := range statements(script) {
+			if _, err := d.db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The bookkeeping always goes through a transaction: clickhouse-go
+	// only accepts INSERTs inside one.
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.dialect.transactional {
+		for _, stmt := range statements(script) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	query, args := d.dialect.record(m, up, time.Now().UTC())
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(225) NOT NULL,
+	done BOOLEAN DEFAULT FALSE
+);
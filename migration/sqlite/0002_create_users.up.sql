@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name varchar(225) not null,
+    email varchar(225) not null UNIQUE,
+    phone varchar(225) not null UNIQUE,
+    password varchar(225) not null,
+    role varchar(225) not null default 'USER',
+    CONSTRAINT proper_email CHECK (email LIKE '%_@_%._%')
+);
@@ -0,0 +1,13 @@
+-- SQLite cannot add a column with a non-constant default, so the rows that
+-- exist already are stamped afterwards, in the format the driver writes.
+ALTER TABLE todos ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+ALTER TABLE todos ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+UPDATE todos SET
+	created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+	updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+CREATE INDEX IF NOT EXISTS todos_created_at_idx ON todos (created_at, id);
+CREATE INDEX IF NOT EXISTS todos_due_at_idx ON todos (due_at);
@@ -0,0 +1,6 @@
+-- Todos that exist already belong to the public owner, which every client
+-- that is not logged in acts as.
+ALTER TABLE todos ADD COLUMN owner_id TEXT NOT NULL DEFAULT 'public';
+
+CREATE INDEX IF NOT EXISTS todos_owner_id_idx ON todos (owner_id, id);
+CREATE INDEX IF NOT EXISTS todos_owner_created_at_idx ON todos (owner_id, created_at, id);
@@ -0,0 +1,18 @@
+-- Lists group the todos of one owner. Todos that exist already are in none.
+CREATE TABLE IF NOT EXISTS todo_lists
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id TEXT NOT NULL,
+    name VARCHAR(225) NOT NULL CHECK (name <> ''),
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS todo_lists_owner_id_idx ON todo_lists (owner_id, archived, id);
+
+-- SQLite cannot drop a column with a foreign key, so unlike on Postgres the
+-- repository alone keeps list_id pointing at an existing list.
+ALTER TABLE todos ADD COLUMN list_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id, id);
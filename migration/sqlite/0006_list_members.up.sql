@@ -0,0 +1,15 @@
+-- The users a list is shared with. The user who created a list is its first
+-- owner, including for the lists that exist already.
+CREATE TABLE IF NOT EXISTS list_members
+(
+    list_id INTEGER NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS list_members_user_id_idx ON list_members (user_id, list_id);
+
+INSERT OR IGNORE INTO list_members (list_id, user_id, role, created_at)
+SELECT id, owner_id, 'owner', created_at FROM todo_lists;
@@ -0,0 +1,6 @@
+-- Subtasks point at their parent todo. As with list_id there is no foreign
+-- key, which SQLite could not drop again: the service deletes the subtasks
+-- of a todo with it.
+ALTER TABLE todos ADD COLUMN parent_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id, id);
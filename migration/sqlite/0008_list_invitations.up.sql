@@ -0,0 +1,14 @@
+-- Invitations to share a list. The token mailed to the invited user names
+-- its row, so it is accepted at most once and stops working when revoked.
+CREATE TABLE IF NOT EXISTS list_invitations
+(
+    id TEXT NOT NULL PRIMARY KEY,
+    list_id INTEGER NOT NULL REFERENCES todo_lists (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS list_invitations_user_id_idx ON list_invitations (list_id, user_id);
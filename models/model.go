@@ -1,59 +1,219 @@
 package models
 
-import (
-	"github.com/gocql/gocql"
-	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
+import "time"
 
 type Todo struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
-	Done  bool   `json:"done"`
+	ID string `json:"id"`
+	// OwnerID is the id of the user the todo belongs to. Like the
+	// timestamps it is set by the server.
+	OwnerID string `json:"owner_id"`
+	// ListID is the id of the list the todo is in, empty when it is in none.
+	// Clients set it when creating the todo; later it only changes by moving
+	// the todo to another list.
+	ListID string `json:"list_id,omitempty"`
+	// ParentID is the id of the todo this one is a subtask of, empty for a
+	// top-level todo. Clients set it when creating the todo; a subtask
+	// belongs to the owner and is in the list of its parent.
+	ParentID    string `json:"parent_id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Done        bool   `json:"done"`
+	// DueAt is when the todo should be done, nil if it has no due date.
+	DueAt    *time.Time `json:"due_at"`
+	Priority int        `json:"priority"`
+	// CreatedAt and UpdatedAt are set by the server; values sent by clients
+	// are ignored.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version is incremented on every update by the backends that support
+	// compare-and-set updates. Sending it back with an update makes the
+	// update fail with a conflict if the todo has changed in the meantime.
+	Version int64 `json:"version,omitempty"`
+	// Children are the subtasks of the todo and Progress counts the
+	// completed ones. Both are only filled in when the subtasks of a todo
+	// are expanded.
+	Children []Todo        `json:"children,omitempty"`
+	Progress *TodoProgress `json:"progress,omitempty"`
 }
 
-type ErrorResponse struct {
-	Message string `json:"message"`
+// TodoProgress is how far a todo with subtasks is: Completed of its Total
+// direct subtasks are done. Ratio is Completed over Total.
+type TodoProgress struct {
+	Completed int64   `json:"completed"`
+	Total     int64   `json:"total"`
+	Ratio     float64 `json:"ratio"`
 }
 
-type TodoMongo struct {
-	ID    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title string             `json:"title" bson:"title"`
-	Done  bool               `json:"done" bson:"done"`
+// ProgressOf counts the completed todos among the subtasks of a todo; it is
+// nil for a todo without subtasks.
+func ProgressOf(children []Todo) *TodoProgress {
+	if len(children) == 0 {
+		return nil
+	}
+	progress := &TodoProgress{Total: int64(len(children))}
+	for _, child := range children {
+		if child.Done {
+			progress.Completed++
+		}
+	}
+	progress.Ratio = float64(progress.Completed) / float64(progress.Total)
+	return progress
 }
-type TodoResponse struct {
-	Todo    *TodoMongo        `json:"todo"`
-	Message map[string]string `json:"message"`
+
+// Priorities range from PriorityNone to PriorityHigh.
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// TodoSortField is a field todo lists can be sorted by.
+type TodoSortField string
+
+const (
+	SortByID        TodoSortField = "id"
+	SortByTitle     TodoSortField = "title"
+	SortByCreatedAt TodoSortField = "created_at"
+)
+
+// TodoSort orders a todo list; ties are broken by id in the same direction.
+// The zero value is the natural order of the backend.
+type TodoSort struct {
+	Field TodoSortField
+	Desc  bool
 }
 
-type TodoElastic struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+// TodoKey is the position of a todo in a sorted list: its id and the value
+// of the field the list is sorted by.
+type TodoKey struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
-type TodoCassandra struct {
-	ID        gocql.UUID `json:"id"`
-	Title     string     `json:"title"`
-	Completed bool       `json:"completed"`
+// TimeRange keeps the todos whose timestamp is at or after From and before
+// To; a nil bound is open.
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
 }
 
-type TodoMaria struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+// TodoQuery selects one page of a todo list.
+type TodoQuery struct {
+	// ListID keeps only the todos of a list when set.
+	ListID string
+	// Done keeps only completed or only open todos when set.
+	Done *bool
+	// Title keeps the todos whose title contains it, ignoring case.
+	Title string
+	// Created and Due keep the todos created and due within a range. Todos
+	// without a due date never match a Due range with a bound.
+	Created TimeRange
+	Due     TimeRange
+	Sort    TodoSort
+	// After is the last todo of the previous page, nil for the first page.
+	After *TodoKey
+	Limit int64
 }
 
-type TodoClickHouse struct {
-	ID    uuid.UUID `json:"id" db:"id"`
-	Title string    `json:"title" db:"title"`
-	Done  uint8     `json:"done" db:"done"`
+// TodoList is a named group of todos, such as "Groceries", that its owner
+// can share with other users, see ListMember. OwnerID is the user who
+// created it. Archived lists keep their todos but take no new ones.
+// TodoCount and DoneCount are computed when the list is read.
+type TodoList struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"owner_id"`
+	Name      string    `json:"name"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TodoCount int64     `json:"todo_count"`
+	DoneCount int64     `json:"done_count"`
 }
 
-type TodoCockroach struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Title     string    `json:"title" db:"title"`
-	Completed bool      `json:"completed" db:"completed"`
+// ListRole is the access a user has to a list and its todos: viewers read
+// them, editors also change the todos and owners also manage the list and
+// the users it is shared with.
+type ListRole string
+
+const (
+	ListViewer ListRole = "viewer"
+	ListEditor ListRole = "editor"
+	ListOwner  ListRole = "owner"
+)
+
+var listRoleRanks = map[ListRole]int{ListViewer: 1, ListEditor: 2, ListOwner: 3}
+
+// Valid reports whether r is one of the list roles.
+func (r ListRole) Valid() bool {
+	_, ok := listRoleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants everything role grants.
+func (r ListRole) Includes(role ListRole) bool {
+	return r.Valid() && listRoleRanks[r] >= listRoleRanks[role]
+}
+
+// ListMember is a user a list is shared with. The user who creates a list is
+// its first owner.
+type ListMember struct {
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      ListRole  `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TodoListQuery selects one page of lists, ordered by id: the archived lists
+// or the active ones.
+type TodoListQuery struct {
+	Archived bool
+	// AfterID is the id of the last list of the previous page, empty for the
+	// first page.
+	AfterID string
+	Limit   int64
+}
+
+// Page is the envelope of every list endpoint. NextCursor requests the
+// following page and is empty on the last one.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// TodoSearchHit is a todo matched by a search with the highlighted fragments
+// of its title. Cursor points just past the hit and resumes the search there.
+type TodoSearchHit struct {
+	Todo       Todo     `json:"todo"`
+	Score      float64  `json:"score"`
+	Highlights []string `json:"highlights"`
+	Cursor     string   `json:"cursor"`
+}
+
+// TodoSearchResult is one page of search hits together with the number of
+// completed and open todos among all matches and spelling suggestions for
+// the query, which are left empty for callers limited to their own todos.
+// Total counts all matches, not only the returned page.
+type TodoSearchResult struct {
+	Hits         []TodoSearchHit `json:"hits"`
+	Total        int64           `json:"total"`
+	HasMore      bool            `json:"has_more"`
+	Completed    int64           `json:"completed"`
+	NotCompleted int64           `json:"not_completed"`
+	Suggestions  []string        `json:"suggestions"`
+}
+
+// BulkTodoResult is the outcome of one todo of a bulk request: the id it was
+// stored under, or why it was rejected.
+type BulkTodoResult struct {
+	ID    string
+	Error string
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
 }
 
 type GenerateTokens struct {
@@ -94,6 +254,15 @@ type Post struct {
 	Password string
 }
 
+// Invitation is the mail that invites a user to a list with a token that
+// accepts the invitation.
+type Invitation struct {
+	Email    string
+	ListName string
+	Role     ListRole
+	Token    string
+}
+
 type RestorePassword struct {
 	Email    string `json:"email" binding:"required" validate:"email"`
 	Password string `json:"password" binding:"required" validate:"password"`
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"newFeatures/models"
+	"sort"
+	"sync"
+)
+
+var ErrUserAlreadyExists = errors.New("user with such email or phone already exists")
+
+// AuthMemory is an in-process AuthorizationApp used together with TodoMemory.
+type AuthMemory struct {
+	mu     sync.RWMutex
+	users  map[int]models.User
+	nextID int
+}
+
+func NewAuthMemory() *AuthMemory {
+	return &AuthMemory{users: make(map[int]models.User)}
+}
+
+func (a *AuthMemory) CreateUser(ctx context.Context, user *models.User) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, u := range a.users {
+		if u.Email == user.Email || u.Phone == user.Phone {
+			return ErrUserAlreadyExists
+		}
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	a.nextID++
+	user.Id = a.nextID
+	a.users[user.Id] = *user
+	return nil
+}
+
+func (a *AuthMemory) UserByPhone(ctx context.Context, user *models.User) (*models.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, u := range a.users {
+		if u.Phone == user.Phone {
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("user with phone %s not found", user.Phone)
+}
+
+func (a *AuthMemory) UserById(ctx context.Context, userID int) (*models.ResponseUser, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &models.ResponseUser{Id: u.Id, Name: u.Name, Email: u.Email, Phone: u.Phone}, nil
+}
+
+func (a *AuthMemory) UserByEmail(ctx context.Context, email string) (*models.ResponseUser, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, u := range a.users {
+		if u.Email == email {
+			return &models.ResponseUser{Id: u.Id, Name: u.Name, Email: u.Email, Phone: u.Phone}, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (a *AuthMemory) Users(ctx context.Context, afterID int, limit int64) ([]models.ResponseUser, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ids := make([]int, 0, len(a.users))
+	for id := range a.users {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	users := []models.ResponseUser{}
+	for i := 0; i < len(ids) && int64(len(users)) < limit; i++ {
+		u := a.users[ids[i]]
+		users = append(users, models.ResponseUser{Id: u.Id, Name: u.Name, Email: u.Email, Phone: u.Phone})
+	}
+	return users, nil
+}
+
+func (a *AuthMemory) UpdateUser(ctx context.Context, inputUser *models.ResponseUser) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.users[inputUser.Id]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	u.Name = inputUser.Name
+	u.Email = inputUser.Email
+	u.Phone = inputUser.Phone
+	a.users[u.Id] = u
+	return nil
+}
+
+func (a *AuthMemory) DeleteUser(ctx context.Context, userID int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.users, userID)
+	return nil
+}
+
+func (a *AuthMemory) UserRoleById(userID int) (*models.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &models.User{Id: u.Id, Role: u.Role}, nil
+}
+
+func (a *AuthMemory) RestorePassword(ctx context.Context, restore *models.RestorePassword) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for id, u := range a.users {
+		if u.Email == restore.Email {
+			u.Password = restore.Password
+			a.users[id] = u
+			return nil
+		}
+	}
+	return nil
+}
+
+func (a *AuthMemory) CheckByEmail(ctx context.Context, restore *models.RestorePassword) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, u := range a.users {
+		if u.Email == restore.Email {
+			return nil
+		}
+	}
+	return fmt.Errorf("user with this email does not exist")
+}
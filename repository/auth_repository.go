@@ -49,17 +49,28 @@ func (a *AuthRepository) UserById(ctx context.Context, userID int) (*models.Resp
 	return &user, nil
 }
 
-func (a *AuthRepository) Users(ctx context.Context, page, limit int64) ([]models.ResponseUser, error) {
-	offset := (page - 1) * limit
-	query := fmt.Sprintf("SELECT id, name, email, phone FROM users OFFSET %d LIMIT %d", offset, limit)
+func (a *AuthRepository) UserByEmail(ctx context.Context, email string) (*models.ResponseUser, error) {
+	var user models.ResponseUser
+	result := a.db.QueryRowContext(ctx, `SELECT id, name, email, phone FROM users WHERE email = $1`, email)
+	if err := result.Scan(&user.Id, &user.Name, &user.Email, &user.Phone); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (a *AuthRepository) Users(ctx context.Context, afterID int, limit int64) ([]models.ResponseUser, error) {
+	query := "SELECT id, name, email, phone FROM users WHERE id > $1 ORDER BY id LIMIT $2"
 
-	rows, err := a.db.QueryContext(ctx, query)
+	rows, err := a.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var users []models.ResponseUser
+	users := []models.ResponseUser{}
 	for rows.Next() {
 		var user models.ResponseUser
 		err := rows.Scan(&user.Id, &user.Name, &user.Email, &user.Phone)
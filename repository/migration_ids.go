@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MigrationIDMap maps the ids of todos on the primary of a TodoMigration to
+// their ids on the secondary. It lives in a file of {"source","target"}
+// lines, the id map cmd/transfer writes, so that a migration started after
+// a transfer knows the todos the transfer copied. Removals are appended as
+// lines with an empty target and dropped when the file is opened again,
+// which rewrites it. Only one process may use the file at a time.
+type MigrationIDMap struct {
+	mu   sync.RWMutex
+	ids  map[string]string
+	file *os.File
+	enc  *json.Encoder
+}
+
+type migrationIDLine struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// OpenMigrationIDMap reads and compacts the id map at path, creating it if
+// it does not exist.
+func OpenMigrationIDMap(path string) (*MigrationIDMap, error) {
+	ids, err := readMigrationIDs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	for source, target := range ids {
+		if err := enc.Encode(migrationIDLine{Source: source, Target: target}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationIDMap{ids: ids, file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func readMigrationIDs(path string) (map[string]string, error) {
+	ids := make(map[string]string)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m migrationIDLine
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			// Only the last line can be torn by a crash.
+			logrus.Warnf("Skipping unreadable id map line %q: %s", scanner.Text(), err)
+			continue
+		}
+		if m.Target == "" {
+			delete(ids, m.Source)
+		} else {
+			ids[m.Source] = m.Target
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// Lookup returns the secondary id of the todo with the primary id.
+func (m *MigrationIDMap) Lookup(primaryID string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	secondaryID, ok := m.ids[primaryID]
+	return secondaryID, ok
+}
+
+// Store records the secondary id of a todo; Delete forgets it.
+func (m *MigrationIDMap) Store(primaryID, secondaryID string) error {
+	return m.write(primaryID, secondaryID)
+}
+
+func (m *MigrationIDMap) Delete(primaryID string) error {
+	return m.write(primaryID, "")
+}
+
+// write appends a line and syncs it before changing the map, so the map
+// never holds what a restart would lose.
+func (m *MigrationIDMap) write(primaryID, secondaryID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.enc.Encode(migrationIDLine{Source: primaryID, Target: secondaryID}); err != nil {
+		return err
+	}
+	if err := m.file.Sync(); err != nil {
+		return err
+	}
+	if secondaryID == "" {
+		delete(m.ids, primaryID)
+	} else {
+		m.ids[primaryID] = secondaryID
+	}
+	return nil
+}
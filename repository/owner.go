@@ -0,0 +1,63 @@
+package repository
+
+import "context"
+
+// PublicOwner owns the todos created without an authenticated user, and the
+// todos stored before todos had owners.
+const PublicOwner = "public"
+
+type ownerCtxKey struct{}
+
+type allOwnersCtxKey struct{}
+
+// WithOwner returns a copy of ctx that carries the id of the user the todo
+// operations are performed for.
+func WithOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerCtxKey{}, ownerID)
+}
+
+// OwnerFromContext returns the owner stored by WithOwner, or PublicOwner.
+func OwnerFromContext(ctx context.Context) string {
+	if ownerID, ok := ctx.Value(ownerCtxKey{}).(string); ok && ownerID != "" {
+		return ownerID
+	}
+	return PublicOwner
+}
+
+// WithAllOwners returns a copy of ctx whose todo operations reach the todos
+// of every owner, for administrators and maintenance jobs. Todos created
+// with it still belong to the owner of ctx.
+func WithAllOwners(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allOwnersCtxKey{}, true)
+}
+
+// ownerScope returns the owner whose todos the operations of ctx are
+// confined to, or "" when they reach every owner.
+func ownerScope(ctx context.Context) string {
+	if all, _ := ctx.Value(allOwnersCtxKey{}).(bool); all {
+		return ""
+	}
+	return OwnerFromContext(ctx)
+}
+
+// InOwnerScope reports whether the operations of ctx may reach a todo of
+// ownerID; an empty ownerID stands for PublicOwner.
+func InOwnerScope(ctx context.Context, ownerID string) bool {
+	if ownerID == "" {
+		ownerID = PublicOwner
+	}
+	scope := ownerScope(ctx)
+	return scope == "" || scope == ownerID
+}
+
+// ownerCondition returns the SQL condition that confines a statement to the
+// owner scope of ctx, appending the owner to args and numbering it with
+// placeholder. It is TRUE when ctx reaches every owner.
+func ownerCondition(ctx context.Context, placeholder func(n int) string, args []interface{}) (string, []interface{}) {
+	owner := ownerScope(ctx)
+	if owner == "" {
+		return "TRUE", args
+	}
+	args = append(args, owner)
+	return "owner_id = " + placeholder(len(args)), args
+}
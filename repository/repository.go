@@ -6,74 +6,108 @@ import (
 	"errors"
 	"newFeatures/models"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/gocql/gocql"
-	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type AppTodoPostgres interface {
-	GetTodoByID(id int) (*models.Todo, error)
-	GetTodos(page, limit int64) ([]models.Todo, int, error)
-	CreateTodo(todo *models.Todo) (int, error)
-	UpdateTodo(todo *models.Todo) error
-	DeleteTodoByID(id int) (int, error)
-}
-type AppTodoMongo interface {
-	GetTodoByID(id primitive.ObjectID) (*models.TodoMongo, error)
-	GetTodos(page, limit int64) ([]models.TodoMongo, int, error)
-	CreateTodo(todo *models.TodoMongo) (string, error)
-	UpdateTodo(todo *models.TodoMongo) error
-	DeleteTodoByID(id primitive.ObjectID) (string, error)
-}
-type AppTodoElasticSearch interface {
-	GetTodoByID(ctx context.Context, id string) (*models.TodoElastic, error)
-	GetTodos(ctx context.Context, page, limit int64) ([]models.TodoElastic, error)
-	CreateTodo(ctx context.Context, input *models.TodoElastic) (string, error)
-	UpdateTodo(ctx context.Context, todo *models.TodoElastic) (string, error)
+// TodoStore is the contract every todo backend implements.
+// IDs are opaque strings; each backend converts them to its native key type.
+type TodoStore interface {
+	GetTodoByID(ctx context.Context, id string) (*models.Todo, error)
+	GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error)
+	// ListTodos returns up to query.Limit todos that pass the filters of
+	// query, in its order, following query.After. Unlike GetTodos it costs
+	// the same for every page.
+	ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error)
+	CountTodos(ctx context.Context) (int64, error)
+	CreateTodo(ctx context.Context, todo *models.Todo) (string, error)
+	UpdateTodo(ctx context.Context, todo *models.Todo) error
 	DeleteTodoByID(ctx context.Context, id string) error
-	SearchTodos(ctx context.Context, query string, page, limit int64) ([]models.TodoElastic, error)
 }
-type AppTodoCassandra interface {
-	CreateTodo(ctx context.Context, todo models.TodoCassandra) error
-	UpdateTodo(ctx context.Context, todo models.TodoCassandra) error
-	DeleteTodoByID(ctx context.Context, id gocql.UUID) error
-	GetTodos(ctx context.Context, page int, limit []byte) ([]models.TodoCassandra, []byte, error)
-	GetTodoByID(ctx context.Context, id gocql.UUID) (models.TodoCassandra, error)
+
+// TodoSearch is implemented by backends that support full-text search.
+type TodoSearch interface {
+	SearchTodos(ctx context.Context, query string, page, limit int64) (*models.TodoSearchResult, error)
+	// SearchTodosAfter returns up to limit todos matching query, or all todos
+	// when query is empty, that come after the hit with the given cursor. An
+	// empty cursor starts from the first hit.
+	SearchTodosAfter(ctx context.Context, query, after string, limit int64) (*models.TodoSearchResult, error)
+	// SuggestTodos completes prefix to up to limit distinct todo titles.
+	SuggestTodos(ctx context.Context, prefix string, limit int64) ([]string, error)
 }
 
-type AppTodoMaria interface {
-	CreateTodo(ctx context.Context, todo *models.TodoMaria) (int, error)
-	UpdateTodo(ctx context.Context, todo *models.TodoMaria) error
-	DeleteTodoByID(ctx context.Context, id int) error
-	GetTodos(ctx context.Context, page int64, limit int64) ([]models.TodoMaria, error)
-	GetTodoByID(ctx context.Context, id int) (models.TodoMaria, error)
+// TodoBulk is implemented by backends that can ingest many todos at once.
+// refresh is BulkRefreshWaitFor, BulkRefreshNone or empty for the default.
+type TodoBulk interface {
+	// BulkCreateTodos stores todos under new ids.
+	BulkCreateTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error)
+	// BulkIndexTodos stores todos under their ids, replacing existing ones.
+	BulkIndexTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error)
+	BulkDeleteTodos(ctx context.Context, ids []string, refresh string) ([]models.BulkTodoResult, error)
 }
 
-type AppTodoClickHouse interface {
-	CreateTodo(ctx context.Context, todo *models.TodoClickHouse) error
-	UpdateTodo(ctx context.Context, todo *models.TodoClickHouse) error
-	DeleteTodo(ctx context.Context, id uuid.UUID) error
-	GetTodos(ctx context.Context, page, limit int64) ([]models.TodoClickHouse, error)
-	GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoClickHouse, error)
+// TodoLists is implemented by backends that group todos into lists that
+// their owners share with other users as a models.ListRole. The owner of the
+// context reaches a list and the todos in it through its role on the list,
+// and the todos in no list through their owner.
+type TodoLists interface {
+	CreateList(ctx context.Context, list *models.TodoList) (string, error)
+	GetList(ctx context.Context, id string) (*models.TodoList, error)
+	// Lists returns up to query.Limit lists that pass the filter of query,
+	// by id, following query.AfterID.
+	Lists(ctx context.Context, query models.TodoListQuery) ([]models.TodoList, error)
+	// UpdateList replaces the name, the archived flag and updated_at.
+	UpdateList(ctx context.Context, list *models.TodoList) error
+	// DeleteList deletes a list together with its todos and returns the ids
+	// of those todos.
+	DeleteList(ctx context.Context, id string) ([]string, error)
+	// MoveTodo puts a todo into the list listID, or into no list when
+	// listID is empty, and sets its updated_at to at. The list must not be
+	// archived and must belong to the owner of the todo.
+	MoveTodo(ctx context.Context, todoID, listID string, at time.Time) error
+	// ListRole returns the role of the owner of ctx on a list.
+	ListRole(ctx context.Context, listID string) (models.ListRole, error)
+	// ListMembers returns the users a list is shared with, by user id.
+	ListMembers(ctx context.Context, listID string) ([]models.ListMember, error)
+	// SetListMember shares a list with a user as role, replacing the role
+	// the user had, and RemoveListMember stops sharing it. Neither checks
+	// the role of the owner of ctx, and both fail with ErrLastListOwner
+	// rather than leave a list without an owner. Both revoke the pending
+	// invitations of the user to the list.
+	SetListMember(ctx context.Context, listID, userID string, role models.ListRole, at time.Time) error
+	RemoveListMember(ctx context.Context, listID, userID string, at time.Time) error
+	// CreateInvitation records the pending invitation id of a user to a
+	// list as role. AcceptInvitation shares the list of a pending
+	// invitation with its user as its role and returns the id of the list;
+	// it fails with ErrInvitationNotFound once the invitation was accepted
+	// or revoked. RevokeInvitations revokes the pending invitations of a
+	// user to a list. None of them checks the role of the owner of ctx.
+	CreateInvitation(ctx context.Context, id, listID, userID string, role models.ListRole, at time.Time) error
+	AcceptInvitation(ctx context.Context, id, userID string, at time.Time) (string, error)
+	RevokeInvitations(ctx context.Context, listID, userID string, at time.Time) error
 }
 
-type AppTodoCockroach interface {
-	CreateTodo(ctx context.Context, todo *models.TodoCockroach) error
-	UpdateTodo(ctx context.Context, todo *models.TodoCockroach) error
-	DeleteTodo(ctx context.Context, id uuid.UUID) error
-	GetTodos(ctx context.Context, page, limit int) ([]models.TodoCockroach, error)
-	GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoCockroach, error)
+// TodoTree is implemented by backends that store subtasks, see
+// models.Todo.ParentID.
+type TodoTree interface {
+	// ChildTodos returns the subtasks of the todos with parentIDs that ctx
+	// may read, by id.
+	ChildTodos(ctx context.Context, parentIDs []string) ([]models.Todo, error)
 }
 
 type AuthorizationApp interface {
 	CreateUser(ctx context.Context, user *models.User) error
 	CheckByEmail(ctx context.Context, restore *models.RestorePassword) error
+	// UserByEmail fails with ErrUserNotFound when no user has the email.
+	UserByEmail(ctx context.Context, email string) (*models.ResponseUser, error)
 	UserById(ctx context.Context, userID int) (*models.ResponseUser, error)
 	UserByPhone(ctx context.Context, user *models.User) (*models.User, error)
-	Users(ctx context.Context, page, limit int64) ([]models.ResponseUser, error)
+	// Users returns up to limit users with an id above afterID, by id.
+	Users(ctx context.Context, afterID int, limit int64) ([]models.ResponseUser, error)
 	UpdateUser(ctx context.Context, inputUser *models.ResponseUser) error
 	DeleteUser(ctx context.Context, userID int) error
 	UserRoleById(userId int) (*models.User, error)
@@ -88,16 +122,64 @@ const (
 	MariaDB         string = "maria"
 	ClickHouseDB    string = "clickhouse"
 	CockroachDB     string = "cockroach"
+	MemoryDB        string = "memory"
+	SQLiteDB        string = "sqlite"
+)
+
+var (
+	ErrTodoNotFound  = errors.New("todo not found")
+	ErrInvalidTodoID = errors.New("invalid todo id")
+	ErrTodoConflict  = errors.New("todo was modified concurrently")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrListNotFound  = errors.New("list not found")
+	ErrInvalidListID = errors.New("invalid list id")
+	ErrInvalidParent = errors.New("invalid parent todo id")
+	ErrListArchived  = errors.New("list is archived")
+	ErrTodoForbidden = errors.New("not enough rights on the list of the todo")
+	ErrLastListOwner = errors.New("a list must keep at least one owner")
+	ErrUserNotFound  = errors.New("user not found")
+
+	ErrListMemberNotFound = errors.New("user is not a member of the list")
+	ErrInvitationNotFound = errors.New("invitation not found")
 )
 
+// intKey parses the numeric id a keyset page starts after; "" starts before
+// the first todo.
+func intKey(after string) (int64, error) {
+	if after == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(after, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// scanNumericTodos reads the rows of the backends whose todo ids are
+// integers into the scan destinations returned by fields, todoFields for
+// the sqlTodoColumns.
+func scanNumericTodos(rows *sql.Rows, fields func(todo *models.Todo, id interface{}) []interface{}) ([]models.Todo, error) {
+	defer rows.Close()
+	todos := []models.Todo{}
+	for rows.Next() {
+		var todo models.Todo
+		var rowID int
+		if err := rows.Scan(fields(&todo, &rowID)...); err != nil {
+			return nil, err
+		}
+		todo.ID = strconv.Itoa(rowID)
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
 type Repository struct {
-	AppTodoPostgres
-	AppTodoMongo
-	AppTodoElasticSearch
-	AppTodoCassandra
-	AppTodoMaria
-	AppTodoClickHouse
-	AppTodoCockroach
+	TodoStore
+	TodoSearch
+	TodoBulk
+	TodoLists
+	TodoTree
 	AuthorizationApp
 }
 
@@ -108,25 +190,38 @@ func NewRepository(dbType string, db interface{}) (*Repository, error) {
 		if !ok {
 			return nil, errors.New("invalid database postgres connection")
 		}
+		postgres := NewTodoPostgres(PostgresDB)
 		return &Repository{
-			AppTodoPostgres:  NewTodoPostgres(PostgresDB),
+			TodoStore:        postgres,
+			TodoSearch:       postgres,
+			TodoLists:        postgres,
+			TodoTree:         postgres,
 			AuthorizationApp: NewAuthRepository(PostgresDB),
 		}, nil
 	case "mongo":
-		MongoDB, ok := db.(*mongo.Client)
+		MongoDB, ok := db.(*mongo.Collection)
 		if !ok {
 			return nil, errors.New("invalid database mongo connection")
 		}
 		return &Repository{
-			AppTodoMongo: NewTodoMongo(MongoDB),
+			TodoStore: NewTodoMongo(MongoDB),
 		}, nil
 	case "elasticsearch":
 		ElasticSearchDB, ok := db.(*elasticsearch.Client)
 		if !ok {
 			return nil, errors.New("invalid database elasticsearch connection")
 		}
+		// Unset or malformed values keep the defaults of the bulk indexer.
+		bulk := ElasticBulkConfig{Refresh: os.Getenv("ELASTIC_BULK_REFRESH")}
+		bulk.FlushBytes, _ = strconv.Atoi(os.Getenv("ELASTIC_BULK_FLUSH_BYTES"))
+		bulk.FlushInterval, _ = time.ParseDuration(os.Getenv("ELASTIC_BULK_FLUSH_INTERVAL"))
+		bulk.Workers, _ = strconv.Atoi(os.Getenv("ELASTIC_BULK_WORKERS"))
+		elastic := NewTodoElasticSearch(ElasticSearchDB, os.Getenv("ELASTIC_INDEX"), bulk)
 		return &Repository{
-			AppTodoElasticSearch: NewTodoElasticSearch(ElasticSearchDB, os.Getenv("ELASTIC_INDEX")),
+			TodoStore:  elastic,
+			TodoSearch: elastic,
+			TodoBulk:   elastic,
+			TodoTree:   elastic,
 		}, nil
 	case "cassandra":
 		CassandraDB, ok := db.(*gocql.Session)
@@ -134,7 +229,7 @@ func NewRepository(dbType string, db interface{}) (*Repository, error) {
 			return nil, errors.New("invalid database cassandra connection")
 		}
 		return &Repository{
-			AppTodoCassandra: NewTodoCassandraDB(CassandraDB),
+			TodoStore: NewTodoCassandraDB(CassandraDB),
 		}, nil
 	case "maria":
 		MariaDB, ok := db.(*sql.DB)
@@ -142,7 +237,7 @@ func NewRepository(dbType string, db interface{}) (*Repository, error) {
 			return nil, errors.New("invalid database maria connection")
 		}
 		return &Repository{
-			AppTodoMaria: NewTodoMaria(MariaDB),
+			TodoStore: NewTodoMaria(MariaDB),
 		}, nil
 	case "clickhouse":
 		ClickHouseDB, ok := db.(*sql.DB)
@@ -150,15 +245,36 @@ func NewRepository(dbType string, db interface{}) (*Repository, error) {
 			return nil, errors.New("invalid database clickhouse connection")
 		}
 		return &Repository{
-			AppTodoClickHouse: NewTodoClickHouseDB(ClickHouseDB),
+			TodoStore: NewTodoClickHouseDB(ClickHouseDB),
 		}, nil
 	case "cockroach":
 		CockroachDB, ok := db.(*sql.DB)
 		if !ok {
 			return nil, errors.New("invalid database cockroach connection")
 		}
+		// Opt-in: reads may then be a few seconds stale.
+		followerReads, _ := strconv.ParseBool(os.Getenv("COCKROACH_FOLLOWER_READS"))
+		return &Repository{
+			TodoStore: NewTodoCockroachDB(CockroachDB, followerReads),
+		}, nil
+	case "sqlite":
+		SQLiteDB, ok := db.(*sql.DB)
+		if !ok {
+			return nil, errors.New("invalid database sqlite connection")
+		}
+		sqlite := NewTodoSQLite(SQLiteDB)
+		return &Repository{
+			TodoStore:        sqlite,
+			TodoLists:        sqlite,
+			TodoTree:         sqlite,
+			AuthorizationApp: NewAuthRepository(SQLiteDB),
+		}, nil
+	case "memory":
+		memory := NewTodoMemory()
 		return &Repository{
-			AppTodoCockroach: NewTodoCockroachDB(CockroachDB),
+			TodoStore:        memory,
+			TodoTree:         memory,
+			AuthorizationApp: NewAuthMemory(),
 		}, nil
 	default:
 		return nil, errors.New("unsupported database type")
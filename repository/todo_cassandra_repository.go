@@ -2,12 +2,22 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"newFeatures/models"
+	"sort"
 
 	"github.com/gocql/gocql"
+	"github.com/sirupsen/logrus"
 )
 
+// TodoCassandra keeps every todo twice: in todos, keyed by id, for lookups,
+// and in todos_by_owner, partitioned by owner and clustered by time UUID, so
+// that listing and counting the todos of one owner read a single partition.
+// Both tables are written in one logged batch on create; updates and deletes
+// are lightweight transactions on todos followed by a plain write to the
+// listing table. Contexts that reach every owner list and count the todos
+// table instead, in token order, the only order across its partitions.
+// Todos written before the listing table existed have no owner until
+// BackfillListing moves them into the partition of PublicOwner.
 type TodoCassandra struct {
 	session *gocql.Session
 }
@@ -16,85 +26,414 @@ func NewTodoCassandraDB(session *gocql.Session) *TodoCassandra {
 	return &TodoCassandra{session: session}
 }
 
-func (r *TodoCassandra) CreateTodo(ctx context.Context, todo models.TodoCassandra) error {
-	todo.ID = gocql.TimeUUID()
+func (r *TodoCassandra) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	id := gocql.TimeUUID()
+	owner := todo.OwnerID
 
-	query := r.session.Query(`
-		INSERT INTO todos (id, title, completed) VALUES (?, ?, ?)
-	`, todo.ID, todo.Title, todo.Completed).WithContext(ctx)
+	batch := r.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+	batch.Query(`
+		INSERT INTO todos (id, owner_id, title, description, completed, due_at, priority, created_at, updated_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+	`, id, owner, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt)
+	batch.Query(`
+		INSERT INTO todos_by_owner (owner_id, id, title, description, completed, due_at, priority, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, owner, id, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt)
 
-	if err := query.Exec(); err != nil {
-		return err
+	if err := r.session.ExecuteBatch(batch); err != nil {
+		return "", err
 	}
 
-	return nil
+	todo.ID = id.String()
+	todo.Version = 1
+	return todo.ID, nil
 }
 
-func (r *TodoCassandra) UpdateTodo(ctx context.Context, todo models.TodoCassandra) error {
-	query := r.session.Query(`
-		UPDATE todos SET title = ?, completed = ? WHERE id = ? IF EXISTS
-	`, todo.Title, todo.Completed, todo.ID).WithContext(ctx)
+// current returns the owner and version recorded for the todo, and
+// ErrTodoNotFound for todos outside the owner scope of ctx. Todos written
+// before the listing table existed have no owner and are kept only in todos;
+// todos written before versioning have a nil version.
+func (r *TodoCassandra) current(ctx context.Context, id gocql.UUID) (string, *int64, error) {
+	var owner string
+	var version *int64
+	if err := r.session.Query(`
+		SELECT owner_id, version FROM todos WHERE id = ?
+	`, id).WithContext(ctx).Scan(&owner, &version); err != nil {
+		if err == gocql.ErrNotFound {
+			return "", nil, ErrTodoNotFound
+		}
+		return "", nil, err
+	}
+	if !InOwnerScope(ctx, owner) {
+		return "", nil, ErrTodoNotFound
+	}
+	return owner, version, nil
+}
 
-	if err := query.Exec(); err != nil {
+// UpdateTodo is a compare-and-set on the version column. When todo.Version
+// is set it must match the stored version, otherwise the update is checked
+// against the version read just before it. Either way a concurrent update
+// makes the lightweight transaction fail and ErrTodoConflict is returned.
+func (r *TodoCassandra) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	id, err := gocql.ParseUUID(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owner, version, err := r.current(ctx, id)
+	if err != nil {
 		return err
 	}
+	var stored int64
+	if version != nil {
+		stored = *version
+	}
+	if todo.Version != 0 && todo.Version != stored {
+		return ErrTodoConflict
+	}
 
+	previous := make(map[string]interface{})
+	applied, err := r.session.Query(`
+		UPDATE todos SET title = ?, description = ?, completed = ?, due_at = ?, priority = ?, updated_at = ?, version = ?
+		WHERE id = ? IF version = ?
+	`, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.UpdatedAt, stored+1, id, version).WithContext(ctx).MapScanCAS(previous)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		// The row either changed or disappeared since it was read.
+		if _, _, err := r.current(ctx, id); err != nil {
+			return err
+		}
+		return ErrTodoConflict
+	}
+	todo.Version = stored + 1
+
+	// The listing copy lives in another partition, which a lightweight
+	// transaction cannot span; it follows the winning update.
+	if owner != "" {
+		return r.session.Query(`
+			UPDATE todos_by_owner SET title = ?, description = ?, completed = ?, due_at = ?, priority = ?, updated_at = ?
+			WHERE owner_id = ? AND id = ?
+		`, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.UpdatedAt, owner, id).WithContext(ctx).Exec()
+	}
 	return nil
 }
 
-func (r *TodoCassandra) DeleteTodoByID(ctx context.Context, id gocql.UUID) error {
-	query := r.session.Query(`
-		DELETE FROM todos WHERE id = ?
-	`, id).WithContext(ctx)
+func (r *TodoCassandra) DeleteTodoByID(ctx context.Context, id string) error {
+	todoID, err := gocql.ParseUUID(id)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owner, _, err := r.current(ctx, todoID)
+	if err != nil {
+		return err
+	}
 
-	if err := query.Exec(); err != nil {
+	applied, err := r.session.Query(`
+		DELETE FROM todos WHERE id = ? IF EXISTS
+	`, todoID).WithContext(ctx).ScanCAS()
+	if err != nil {
 		return err
 	}
+	if !applied {
+		return ErrTodoNotFound
+	}
 
+	if owner != "" {
+		return r.session.Query(`
+			DELETE FROM todos_by_owner WHERE owner_id = ? AND id = ?
+		`, owner, todoID).WithContext(ctx).Exec()
+	}
 	return nil
 }
 
-func (r *TodoCassandra) GetTodos(ctx context.Context, page int, limit []byte) ([]models.TodoCassandra, []byte, error) {
-	query := r.session.Query("SELECT id, title, completed FROM todos").WithContext(ctx)
+// GetTodos lists the partition of the owner in ctx, newest first, or the
+// todos table for contexts that reach every owner. Page/limit is emulated on
+// top of Cassandra paging state: the pages before the requested one are
+// fetched and discarded.
+func (r *TodoCassandra) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	cql := "SELECT " + cassandraTodoColumns + " FROM todos_by_owner WHERE owner_id = ?"
+	args := []interface{}{OwnerFromContext(ctx)}
+	if ownerScope(ctx) == "" {
+		cql, args = "SELECT "+cassandraTodoColumns+" FROM todos", nil
+	}
+	query := r.session.Query(cql, args...).WithContext(ctx)
+	query.PageSize(int(limit))
 
-	query.PageSize(page)
-	query.PageState(limit)
+	var pageState []byte
+	for i := int64(1); i < page; i++ {
+		iter := query.PageState(pageState).Iter()
+		pageState = iter.PageState()
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		if len(pageState) == 0 {
+			return []models.Todo{}, nil
+		}
+	}
 
-	iter := query.Iter()
-	defer iter.Close()
+	iter := query.PageState(pageState).Iter()
 
-	todos := make([]models.TodoCassandra, 0)
-	var id gocql.UUID
-	var title string
-	var completed bool
+	todos := make([]models.Todo, 0)
+	for int64(len(todos)) < limit {
+		todo, ok := scanCassandraTodo(iter)
+		if !ok {
+			break
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// ListTodos reads the partition of the owner in ctx, newest first unless
+// sorted otherwise. The ids are time UUIDs, so the id order is also the
+// creation order and the created range is a slice of the partition. The
+// completed filter uses the todos_by_owner_completed index; Cassandra cannot
+// combine an index with ORDER BY, so oldest-first lists check it while
+// reading instead, as every list does for the title filter and the due
+// range, which CQL cannot express. Sorting by title has no clustering order
+// to use and is done in memory over the whole partition. Contexts that reach
+// every owner get listAllTodos.
+func (r *TodoCassandra) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	if ownerScope(ctx) == "" {
+		return r.listAllTodos(ctx, query)
+	}
+	byTitle := query.Sort.Field == models.SortByTitle
+	ascending := !byTitle && query.Sort.Field != "" && !query.Sort.Desc
+	indexed := query.Done != nil && !ascending
+
+	cql := "SELECT " + cassandraTodoColumns + " FROM todos_by_owner WHERE owner_id = ?"
+	args := []interface{}{OwnerFromContext(ctx)}
+	if indexed {
+		cql += " AND completed = ?"
+		args = append(args, *query.Done)
+	}
+	// A bound of id may only be given once. The cursor, which lies within
+	// the created range, replaces the range bound on its side.
+	from, to := query.Created.From, query.Created.To
+	if query.After != nil && !byTitle {
+		afterID, err := gocql.ParseUUID(query.After.ID)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		if ascending {
+			cql += " AND id > ?"
+			from = nil
+		} else {
+			cql += " AND id < ?"
+			to = nil
+		}
+		args = append(args, afterID)
+	}
+	if from != nil {
+		cql += " AND id >= minTimeuuid(?)"
+		args = append(args, *from)
+	}
+	if to != nil {
+		cql += " AND id < minTimeuuid(?)"
+		args = append(args, *to)
+	}
+	if ascending {
+		cql += " ORDER BY id ASC"
+	}
+	if indexed {
+		// Only filters within the partition, which is read anyway.
+		cql += " ALLOW FILTERING"
+	}
 
-	for iter.Scan(&id, &title, &completed) {
-		todos = append(todos, models.TodoCassandra{
-			ID:        id,
-			Title:     title,
-			Completed: completed,
-		})
+	q := r.session.Query(cql, args...).WithContext(ctx)
+	if !byTitle {
+		q.PageSize(int(query.Limit))
 	}
+	iter := q.Iter()
 
+	todos := make([]models.Todo, 0)
+	for byTitle || int64(len(todos)) < query.Limit {
+		todo, ok := scanCassandraTodo(iter)
+		if !ok {
+			break
+		}
+		if matchesTodo(query, todo) {
+			todos = append(todos, todo)
+		}
+	}
 	if err := iter.Close(); err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if !byTitle {
+		return todos, nil
 	}
 
-	newPagingState := iter.PageState()
+	less := func(titleA, idA, titleB, idB string) bool {
+		if titleA != titleB {
+			return titleA < titleB != query.Sort.Desc
+		}
+		return idA != idB && idA < idB != query.Sort.Desc
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return less(todos[i].Title, todos[i].ID, todos[j].Title, todos[j].ID)
+	})
+	page := make([]models.Todo, 0, query.Limit)
+	for _, todo := range todos {
+		if int64(len(page)) == query.Limit {
+			break
+		}
+		if query.After == nil || less(query.After.Title, query.After.ID, todo.Title, todo.ID) {
+			page = append(page, todo)
+		}
+	}
+	return page, nil
+}
 
-	return todos, newPagingState, nil
+// listAllTodos reads the todos table in token order whatever the sort of
+// query, since it has no other order across partitions; the cursor is the
+// token of the id of the last todo. Every filter is checked while reading.
+func (r *TodoCassandra) listAllTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	cql := "SELECT " + cassandraTodoColumns + " FROM todos"
+	var args []interface{}
+	if query.After != nil {
+		afterID, err := gocql.ParseUUID(query.After.ID)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cql += " WHERE token(id) > token(?)"
+		args = append(args, afterID)
+	}
+
+	q := r.session.Query(cql, args...).WithContext(ctx)
+	q.PageSize(int(query.Limit))
+	iter := q.Iter()
+
+	todos := make([]models.Todo, 0)
+	for int64(len(todos)) < query.Limit {
+		todo, ok := scanCassandraTodo(iter)
+		if !ok {
+			break
+		}
+		if matchesTodo(query, todo) {
+			todos = append(todos, todo)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// CountTodos counts the partition of the owner in ctx, or scans the todos
+// table for contexts that reach every owner.
+func (r *TodoCassandra) CountTodos(ctx context.Context) (int64, error) {
+	cql := "SELECT COUNT(*) FROM todos_by_owner WHERE owner_id = ?"
+	args := []interface{}{OwnerFromContext(ctx)}
+	if ownerScope(ctx) == "" {
+		cql, args = "SELECT COUNT(*) FROM todos", nil
+	}
+	var count int64
+	if err := r.session.Query(cql, args...).WithContext(ctx).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-func (r *TodoCassandra) GetTodoByID(ctx context.Context, id gocql.UUID) (models.TodoCassandra, error) {
-	var todo models.TodoCassandra
+// BackfillListing gives the todos written before the listing table existed
+// to PublicOwner and copies them into its partition, so that they are
+// listed. It returns how many todos it moved; running it again moves none.
+// Todos whose id is not a time UUID cannot be clustered by time and are
+// left out with a warning. The listing copy is written from the row read, so
+// the old todos should not be updated while it runs.
+func (r *TodoCassandra) BackfillListing(ctx context.Context) (int64, error) {
+	iter := r.session.Query("SELECT " + cassandraTodoColumns + " FROM todos").WithContext(ctx).Iter()
+	var moved int64
+	for {
+		var todo models.Todo
+		var id gocql.UUID
+		if !iter.Scan(todoFields(&todo, &id)...) {
+			break
+		}
+		if todo.OwnerID != "" {
+			continue
+		}
+		if id.Version() != 1 {
+			logrus.Warnf("BackfillListing: todo %s has no time UUID and stays unlisted", id)
+			continue
+		}
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = id.Time()
+		}
+
+		batch := r.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+		batch.Query(`
+			UPDATE todos SET owner_id = ? WHERE id = ?
+		`, PublicOwner, id)
+		batch.Query(`
+			INSERT INTO todos_by_owner (owner_id, id, title, description, completed, due_at, priority, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, PublicOwner, id, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt)
+		if err := r.session.ExecuteBatch(batch); err != nil {
+			iter.Close()
+			return moved, err
+		}
+		moved++
+	}
+	return moved, iter.Close()
+}
+
+func (r *TodoCassandra) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrInvalidTodoID
+	}
+
+	var uuid gocql.UUID
+	var todo models.Todo
+	var version *int64
 	if err := r.session.Query(`
-		SELECT id, title, completed FROM todos WHERE id = ?
-	`, id).WithContext(ctx).Scan(&todo.ID, &todo.Title, &todo.Completed); err != nil {
+		SELECT `+cassandraTodoColumns+`, version FROM todos WHERE id = ?
+	`, todoID).WithContext(ctx).Scan(append(todoFields(&todo, &uuid), &version)...); err != nil {
 		if err == gocql.ErrNotFound {
-			return models.TodoCassandra{}, errors.New("todo not found")
+			return nil, ErrTodoNotFound
 		}
-		return models.TodoCassandra{}, err
+		return nil, err
 	}
 
-	return todo, nil
+	if !InOwnerScope(ctx, todo.OwnerID) {
+		return nil, ErrTodoNotFound
+	}
+	todo.ID = uuid.String()
+	if todo.OwnerID == "" {
+		todo.OwnerID = PublicOwner
+	}
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = uuid.Time()
+	}
+	if version != nil {
+		todo.Version = *version
+	}
+	return &todo, nil
+}
+
+var cassandraTodoColumns = sqlTodoColumns("completed")
+
+// scanCassandraTodo reads the next cassandraTodoColumns row of iter. Todos
+// written before they had owners belong to PublicOwner, and those written
+// before they had timestamps count as created at the time of their id.
+func scanCassandraTodo(iter *gocql.Iter) (models.Todo, bool) {
+	var todo models.Todo
+	var id gocql.UUID
+	if !iter.Scan(todoFields(&todo, &id)...) {
+		return todo, false
+	}
+	todo.ID = id.String()
+	if todo.OwnerID == "" {
+		todo.OwnerID = PublicOwner
+	}
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = id.Time()
+	}
+	return todo, true
 }
@@ -3,12 +3,20 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"newFeatures/models"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// TodoClickHouse stores todos in a ReplacingMergeTree keyed by id. Rows are
+// never changed in place: an update inserts a newer version of the todo and
+// a delete inserts a tombstone, and ClickHouse keeps only the highest
+// version of each id when it merges parts. Since the tombstone is the
+// highest version, merges keep it, so deleted todos stay in the table as
+// tombstones. Until a merge has happened reads have to collapse the versions
+// themselves, with FINAL or argMax, and every read skips the tombstones.
 type TodoClickHouse struct {
 	DB *sql.DB
 }
@@ -17,98 +25,176 @@ func NewTodoClickHouseDB(db *sql.DB) *TodoClickHouse {
 	return &TodoClickHouse{DB: db}
 }
 
-func (r *TodoClickHouse) GetTodos(ctx context.Context, page, limit int64) ([]models.TodoClickHouse, error) {
-	offset := (page - 1) * limit
+func (r *TodoClickHouse) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	owned, args := ownerCondition(ctx, questionPlaceholder, nil)
+	args = append(args, (page-1)*limit, limit)
 
-	query := fmt.Sprintf("SELECT id, title, done FROM todos LIMIT %d, %d", offset, limit)
-	rows, err := r.DB.QueryContext(ctx, query)
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+clickHouseTodoColumns+" FROM todos FINAL WHERE deleted = 0 AND "+owned+" ORDER BY id LIMIT ?, ?", args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanClickHouseTodos(rows)
+}
+
+var clickHouseTodoColumns = sqlTodoColumns("done")
 
-	var todos []models.TodoClickHouse
+// scanClickHouseTodos reads clickHouseTodoColumns rows. database/sql
+// converts the UInt8 done and priority columns.
+func scanClickHouseTodos(rows *sql.Rows) ([]models.Todo, error) {
+	defer rows.Close()
+	todos := []models.Todo{}
 	for rows.Next() {
-		var todo models.TodoClickHouse
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Done)
-		if err != nil {
+		var todo models.Todo
+		var id uuid.UUID
+		if err := rows.Scan(todoFields(&todo, &id)...); err != nil {
 			return nil, err
 		}
+		todo.ID = id.String()
 		todos = append(todos, todo)
 	}
+	return todos, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return todos, nil
+var clickHouseTodoList = sqlTodoList{
+	placeholder: questionPlaceholder,
+	done:        "done",
+	doneValue: func(done bool) interface{} {
+		if done {
+			return uint8(1)
+		}
+		return uint8(0)
+	},
+	key: func(id string) (interface{}, error) {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return id, nil
+	},
+	id: func(param string) string {
+		return "toUUID(" + param + ")"
+	},
+	contains: func(arg func(interface{}) string, value string) string {
+		return "positionCaseInsensitiveUTF8(title, " + arg(value) + ") > 0"
+	},
+	// clickhouse-go binds a time.Time with second precision only.
+	at: func(arg func(interface{}) string, t time.Time) string {
+		return "toDateTime64(" + arg(t.UTC().Format("2006-01-02 15:04:05.000")) + ", 3, 'UTC')"
+	},
 }
 
-func (r *TodoClickHouse) GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoClickHouse, error) {
-	var todo models.TodoClickHouse
-	err := r.DB.QueryRowContext(ctx, "SELECT id, title, done FROM todos WHERE id = ?", id).Scan(&todo.ID, &todo.Title, &todo.Done)
+func (r *TodoClickHouse) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	clauses, args, err := clickHouseTodoList.build(ctx, query, "deleted = 0")
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("todo not found")
-		}
 		return nil, err
 	}
-	return &todo, nil
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+clickHouseTodoColumns+" FROM todos FINAL"+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanClickHouseTodos(rows)
 }
 
-func (r *TodoClickHouse) CreateTodo(ctx context.Context, todo *models.TodoClickHouse) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
+func (r *TodoClickHouse) CountTodos(ctx context.Context) (int64, error) {
+	var count uint64
+	owned, args := ownerCondition(ctx, questionPlaceholder, nil)
+	err := r.DB.QueryRowContext(ctx, "SELECT count() FROM todos FINAL WHERE deleted = 0 AND "+owned, args...).Scan(&count)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return int64(count), nil
+}
 
-	_, err = tx.ExecContext(ctx, "INSERT INTO todos (title, done) VALUES (?, ?)", todo.Title, todo.Done)
+// GetTodoByID picks the latest version with argMax, which only reads the
+// rows of one id instead of collapsing the whole table like FINAL. argMax
+// skips NULLs, so the nullable due_at is wrapped in a tuple. The owner of a
+// todo never changes, so every version has it.
+func (r *TodoClickHouse) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := uuid.Parse(id)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, ErrInvalidTodoID
 	}
-
-	err = tx.Commit()
+	todo := models.Todo{ID: todoID.String()}
+	var deleted uint8
+	owned, args := ownerCondition(ctx, questionPlaceholder, []interface{}{todoID})
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT any(owner_id), argMax(title, version), argMax(description, version), argMax(done, version),
+			argMax(tuple(due_at), version).1, argMax(priority, version),
+			argMax(created_at, version), argMax(updated_at, version), argMax(deleted, version)
+		FROM todos
+		WHERE id = ? AND `+owned+`
+		GROUP BY id`, args...).Scan(append(todoFields(&todo, nil)[1:], &deleted)...)
 	if err != nil {
-		tx.Rollback()
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
 	}
-
-	return nil
+	if deleted == 1 {
+		return nil, ErrTodoNotFound
+	}
+	return &todo, nil
 }
 
-func (r *TodoClickHouse) UpdateTodo(ctx context.Context, todo *models.TodoClickHouse) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+func (r *TodoClickHouse) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	todo.ID = uuid.New().String()
+
+	if err := r.insertVersion(ctx, todo, false); err != nil {
+		return "", err
 	}
-	_, err = tx.ExecContext(ctx, "ALTER TABLE todos UPDATE title = ?, done = ? WHERE id = ?", todo.Title, todo.Done, todo.ID)
+	return todo.ID, nil
+}
+
+// UpdateTodo inserts a new version of the todo. Two concurrent updates of the
+// same todo both succeed and the one with the later version wins.
+func (r *TodoClickHouse) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	current, err := r.GetTodoByID(ctx, todo.ID)
 	if err != nil {
-		_ = tx.Rollback()
 		return err
 	}
+	todo.OwnerID = current.OwnerID
+	todo.CreatedAt = current.CreatedAt
+	return r.insertVersion(ctx, todo, false)
+}
 
-	if err = tx.Commit(); err != nil {
+// DeleteTodoByID inserts a tombstone that hides the todo from every read.
+// Merges drop the older versions of the todo but keep the tombstone.
+func (r *TodoClickHouse) DeleteTodoByID(ctx context.Context, id string) error {
+	todo, err := r.GetTodoByID(ctx, id)
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return r.insertVersion(ctx, todo, true)
 }
 
-func (r *TodoClickHouse) DeleteTodo(ctx context.Context, id uuid.UUID) error {
+// insertVersion writes a row whose version is the current time in
+// nanoseconds, so later writes always replace earlier ones. clickhouse-go
+// only accepts INSERTs inside a transaction.
+func (r *TodoClickHouse) insertVersion(ctx context.Context, todo *models.Todo, deleted bool) error {
+	todoID, err := uuid.Parse(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
 	tx, err := r.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	_, err = tx.ExecContext(ctx, "ALTER TABLE todos DELETE WHERE id = ?", id)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO todos (id, owner_id, title, description, done, due_at, priority, created_at, updated_at, version, deleted)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		todoID.String(), todo.OwnerID, todo.Title, todo.Description, boolToUInt8(todo.Done), todo.DueAt, uint8(todo.Priority),
+		todo.CreatedAt, todo.UpdatedAt, uint64(time.Now().UnixNano()), boolToUInt8(deleted))
 	if err != nil {
 		_ = tx.Rollback()
 		return err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
-	}
+	return tx.Commit()
+}
 
-	return nil
+func boolToUInt8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
 }
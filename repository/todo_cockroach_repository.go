@@ -3,93 +3,211 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand"
 	"newFeatures/models"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
 )
 
 type TodoCockroach struct {
 	DB *sql.DB
+	// followerReads serves reads from the closest replica as of
+	// follower_read_timestamp(), a few seconds in the past, instead of
+	// contending with writers on the leaseholder.
+	followerReads bool
 }
 
-func NewTodoCockroachDB(db *sql.DB) *TodoCockroach {
-	return &TodoCockroach{DB: db}
+func NewTodoCockroachDB(db *sql.DB, followerReads bool) *TodoCockroach {
+	return &TodoCockroach{DB: db, followerReads: followerReads}
 }
 
-func (r *TodoCockroach) GetTodos(ctx context.Context, page, limit int) ([]models.TodoCockroach, error) {
-	offset := (page - 1) * limit
-	query := fmt.Sprintf("SELECT id, title, completed FROM todos LIMIT %d OFFSET %d", limit, offset)
+const (
+	cockroachMaxRetries  = 5
+	cockroachBaseBackoff = 20 * time.Millisecond
+	// SQLSTATE of the serialization failures CockroachDB expects clients to retry.
+	cockroachRetryCode = "40001"
+)
+
+// executeTx runs fn in a transaction and commits it. When CockroachDB aborts
+// the transaction with a serialization failure the whole transaction is
+// retried with exponential backoff and jitter; any other error is returned
+// as is.
+func (r *TodoCockroach) executeTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	backoff := cockroachBaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := r.runTx(ctx, fn)
+		var pqErr *pq.Error
+		if err == nil || !errors.As(err, &pqErr) || pqErr.Code != cockroachRetryCode || attempt == cockroachMaxRetries {
+			return err
+		}
 
-	rows, err := r.DB.QueryContext(ctx, query)
+		logrus.Warnf("TodoCockroach: retrying transaction after serialization failure (attempt %d): %s", attempt, err)
+		select {
+		case <-time.After(backoff + time.Duration(rand.Int63n(int64(backoff)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (r *TodoCockroach) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// asOf returns the AS OF SYSTEM TIME clause used by reads in follower read mode.
+func (r *TodoCockroach) asOf() string {
+	if r.followerReads {
+		return " AS OF SYSTEM TIME follower_read_timestamp()"
+	}
+	return ""
+}
+
+func (r *TodoCockroach) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	owned, args := ownerCondition(ctx, dollarPlaceholder, nil)
+	args = append(args, limit, (page-1)*limit)
+	rows, err := r.DB.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM todos%s WHERE %s ORDER BY id LIMIT $%d OFFSET $%d",
+		cockroachTodoColumns, r.asOf(), owned, len(args)-1, len(args)), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	todos := []models.TodoCockroach{}
+	todos := []models.Todo{}
 	for rows.Next() {
-		var todo models.TodoCockroach
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Completed)
+		var todo models.Todo
+		err := rows.Scan(todoFields(&todo, &todo.ID)...)
 		if err != nil {
 			return nil, err
 		}
 		todos = append(todos, todo)
 	}
 
-	return todos, nil
+	return todos, rows.Err()
+}
+
+var cockroachTodoColumns = sqlTodoColumns("completed")
+
+var cockroachTodoList = sqlTodoList{
+	placeholder: dollarPlaceholder,
+	done:        "completed",
+	key: func(id string) (interface{}, error) {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return id, nil
+	},
+	id:       plainID,
+	contains: lowerLike,
 }
 
-func (r *TodoCockroach) GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoCockroach, error) {
-	var todo models.TodoCockroach
-	err := r.DB.QueryRowContext(ctx, "SELECT id, title, completed FROM todos WHERE id = $1", id).Scan(&todo.ID, &todo.Title, &todo.Completed)
+func (r *TodoCockroach) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	clauses, args, err := cockroachTodoList.build(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	return &todo, nil
-}
-
-func (r *TodoCockroach) CreateTodo(ctx context.Context, todo *models.TodoCockroach) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+cockroachTodoColumns+" FROM todos"+r.asOf()+clauses, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tx.Rollback()
+	defer rows.Close()
 
-	_, err = tx.ExecContext(ctx, "INSERT INTO todos (title, completed) VALUES ($1, $2)", todo.Title, todo.Completed)
-	if err != nil {
-		return err
+	todos := []models.Todo{}
+	for rows.Next() {
+		var todo models.Todo
+		if err := rows.Scan(todoFields(&todo, &todo.ID)...); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
 	}
 
-	return tx.Commit()
+	return todos, rows.Err()
 }
 
-func (r *TodoCockroach) UpdateTodo(ctx context.Context, todo *models.TodoCockroach) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
+func (r *TodoCockroach) CountTodos(ctx context.Context) (int64, error) {
+	var count int64
+	owned, args := ownerCondition(ctx, dollarPlaceholder, nil)
+	err := r.DB.QueryRowContext(ctx, "SELECT count(*) FROM todos"+r.asOf()+" WHERE "+owned, args...).Scan(&count)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer tx.Rollback()
+	return count, nil
+}
 
-	_, err = tx.ExecContext(ctx, "UPDATE todos SET title = $1, completed = $2 WHERE id = $3", todo.Title, todo.Completed, todo.ID)
+func (r *TodoCockroach) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := uuid.Parse(id)
 	if err != nil {
-		return err
+		return nil, ErrInvalidTodoID
 	}
-
-	return tx.Commit()
+	var todo models.Todo
+	owned, args := ownerCondition(ctx, dollarPlaceholder, []interface{}{todoID})
+	err = r.DB.QueryRowContext(ctx, "SELECT "+cockroachTodoColumns+" FROM todos"+r.asOf()+" WHERE id = $1 AND "+owned, args...).Scan(todoFields(&todo, &todo.ID)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+	return &todo, nil
 }
 
-func (r *TodoCockroach) DeleteTodo(ctx context.Context, id uuid.UUID) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
+func (r *TodoCockroach) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	err := r.executeTx(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO todos (owner_id, title, description, completed, due_at, priority, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			todo.OwnerID, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt).Scan(&todo.ID)
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer tx.Rollback()
+	return todo.ID, nil
+}
 
-	_, err = tx.ExecContext(ctx, "DELETE FROM todos WHERE id = $1", id)
+func (r *TodoCockroach) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	todoID, err := uuid.Parse(todo.ID)
 	if err != nil {
-		return err
+		return ErrInvalidTodoID
 	}
+	owned, args := ownerCondition(ctx, dollarPlaceholder, []interface{}{
+		todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.UpdatedAt, todoID,
+	})
+	return r.executeTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE todos SET title = $1, description = $2, completed = $3, due_at = $4, priority = $5, updated_at = $6
+			WHERE id = $7 AND `+owned, args...)
+		if err != nil {
+			return err
+		}
+		return checkRowsAffected(result)
+	})
+}
 
-	return tx.Commit()
+func (r *TodoCockroach) DeleteTodoByID(ctx context.Context, id string) error {
+	todoID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owned, args := ownerCondition(ctx, dollarPlaceholder, []interface{}{todoID})
+	return r.executeTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM todos WHERE id = $1 AND "+owned, args...)
+		if err != nil {
+			return err
+		}
+		return checkRowsAffected(result)
+	})
 }
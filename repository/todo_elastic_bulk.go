@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"newFeatures/models"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/google/uuid"
+)
+
+// Refresh policies of a bulk request: wait_for returns once the todos are
+// visible to searches, none returns as soon as they are written.
+const (
+	BulkRefreshWaitFor = "wait_for"
+	BulkRefreshNone    = "none"
+)
+
+// ElasticBulkConfig tunes the bulk indexer. Zero values fall back to the
+// defaults of esutil: 5MB per request, a flush every 30 seconds and one
+// worker per CPU.
+type ElasticBulkConfig struct {
+	FlushBytes    int
+	FlushInterval time.Duration
+	Workers       int
+	// Refresh is used when a request does not pick a policy itself.
+	Refresh string
+}
+
+// BulkCreateTodos indexes todos through the _bulk API under new ids.
+// Requests are flushed whenever FlushBytes of todos are queued or
+// FlushInterval has passed, and several of them are in flight at once. The
+// result has one entry per todo, in input order, holding either its new id
+// or the reason it was rejected; the error is only set when the indexer
+// itself fails.
+func (e *ElasticSearch) BulkCreateTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error) {
+	items := make([]esutil.BulkIndexerItem, len(todos))
+	for i := range todos {
+		todo := todos[i]
+		todo.ID = uuid.New().String()
+		doc, err := json.Marshal(newElasticTodo(&todo))
+		if err != nil {
+			return nil, err
+		}
+		items[i] = esutil.BulkIndexerItem{Action: "create", DocumentID: todo.ID, Body: bytes.NewReader(doc)}
+	}
+	return e.bulkWrite(ctx, refresh, items)
+}
+
+// BulkIndexTodos stores todos under their own ids, replacing the documents
+// that already exist. Like BulkDeleteTodos it is meant for syncing the index
+// and ignores the owner scope of ctx.
+func (e *ElasticSearch) BulkIndexTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error) {
+	items := make([]esutil.BulkIndexerItem, len(todos))
+	for i := range todos {
+		doc, err := json.Marshal(newElasticTodo(&todos[i]))
+		if err != nil {
+			return nil, err
+		}
+		items[i] = esutil.BulkIndexerItem{Action: "index", DocumentID: todos[i].ID, Body: bytes.NewReader(doc)}
+	}
+	return e.bulkWrite(ctx, refresh, items)
+}
+
+// BulkDeleteTodos removes the todos with the given ids. Todos that do not
+// exist count as deleted.
+func (e *ElasticSearch) BulkDeleteTodos(ctx context.Context, ids []string, refresh string) ([]models.BulkTodoResult, error) {
+	items := make([]esutil.BulkIndexerItem, len(ids))
+	for i, id := range ids {
+		items[i] = esutil.BulkIndexerItem{Action: "delete", DocumentID: id}
+	}
+	return e.bulkWrite(ctx, refresh, items)
+}
+
+func (e *ElasticSearch) bulkWrite(ctx context.Context, refresh string, items []esutil.BulkIndexerItem) ([]models.BulkTodoResult, error) {
+	if refresh == "" {
+		refresh = e.bulk.Refresh
+	}
+	switch refresh {
+	case BulkRefreshWaitFor:
+	case BulkRefreshNone, "":
+		refresh = "false"
+	default:
+		return nil, fmt.Errorf("unknown refresh policy %q", refresh)
+	}
+
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Client:        e.client,
+		Index:         e.index,
+		NumWorkers:    e.bulk.Workers,
+		FlushBytes:    e.bulk.FlushBytes,
+		FlushInterval: e.bulk.FlushInterval,
+		Refresh:       refresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every item only writes its own slot, so the workers need no lock.
+	results := make([]models.BulkTodoResult, len(items))
+	for i := range items {
+		i := i
+		item := items[i]
+		item.OnSuccess = func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+			results[i].ID = item.DocumentID
+		}
+		item.OnFailure = func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+			switch {
+			case err != nil:
+				results[i].Error = err.Error()
+			case item.Action == "delete" && res.Status == http.StatusNotFound:
+				results[i].ID = item.DocumentID
+			default:
+				results[i].Error = fmt.Sprintf("%s: %s", res.Error.Type, res.Error.Reason)
+			}
+		}
+		if err := indexer.Add(ctx, item); err != nil {
+			// The context is done; what was queued is still flushed.
+			indexer.Close(context.Background())
+			return nil, err
+		}
+	}
+
+	if err := indexer.Close(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
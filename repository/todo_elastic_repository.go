@@ -3,9 +3,13 @@ package repository
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"newFeatures/models"
 
@@ -16,31 +20,160 @@ import (
 
 type ElasticSearch struct {
 	client *elasticsearch.Client
-	index  string
+	// index is the alias maintained by database.EnsureElasticIndex; the
+	// versioned index behind it can be swapped without touching this code.
+	index string
+	bulk  ElasticBulkConfig
 }
 
-func NewTodoElasticSearch(es *elasticsearch.Client, index string) *ElasticSearch {
+func NewTodoElasticSearch(es *elasticsearch.Client, index string, bulk ElasticBulkConfig) *ElasticSearch {
 	return &ElasticSearch{
 		client: es,
 		index:  index,
+		bulk:   bulk,
+	}
+}
+
+// elasticTodo is the _source of a todo in the index. The owner, the parent
+// and the timestamps are left out when they are zero, so that the partial
+// document of an update keeps owner_id, parent_id and created_at.
+type elasticTodo struct {
+	ID          string     `json:"id"`
+	OwnerID     string     `json:"owner_id,omitempty"`
+	ParentID    string     `json:"parent_id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"due_at"`
+	Priority    int        `json:"priority"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+func newElasticTodo(todo *models.Todo) elasticTodo {
+	doc := elasticTodo{
+		ID:          todo.ID,
+		OwnerID:     todo.OwnerID,
+		ParentID:    todo.ParentID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Done,
+		DueAt:       todo.DueAt,
+		Priority:    todo.Priority,
+	}
+	if !todo.CreatedAt.IsZero() {
+		doc.CreatedAt = &todo.CreatedAt
+	}
+	if !todo.UpdatedAt.IsZero() {
+		doc.UpdatedAt = &todo.UpdatedAt
+	}
+	return doc
+}
+
+// toModel converts the document with the given id. Documents indexed before
+// todos had owners belong to PublicOwner.
+func (d elasticTodo) toModel(id string) models.Todo {
+	todo := models.Todo{
+		ID:          id,
+		OwnerID:     d.OwnerID,
+		ParentID:    d.ParentID,
+		Title:       d.Title,
+		Description: d.Description,
+		Done:        d.Completed,
+		DueAt:       d.DueAt,
+		Priority:    d.Priority,
+	}
+	if todo.OwnerID == "" {
+		todo.OwnerID = PublicOwner
+	}
+	if d.CreatedAt != nil {
+		todo.CreatedAt = *d.CreatedAt
+	}
+	if d.UpdatedAt != nil {
+		todo.UpdatedAt = *d.UpdatedAt
+	}
+	return todo
+}
+
+// elasticOwnerFilter returns the filter that confines a search to the owner
+// scope of ctx, nil when ctx reaches every owner. The documents without an
+// owner belong to PublicOwner.
+func elasticOwnerFilter(ctx context.Context) map[string]interface{} {
+	owner := ownerScope(ctx)
+	if owner == "" {
+		return nil
+	}
+	filter := map[string]interface{}{"term": map[string]interface{}{"owner_id": owner}}
+	if owner != PublicOwner {
+		return filter
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				filter,
+				map[string]interface{}{"bool": map[string]interface{}{
+					"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "owner_id"}},
+				}},
+			},
+		},
+	}
+}
+
+// ownedQuery confines query to the owner scope of ctx.
+func ownedQuery(ctx context.Context, query interface{}) interface{} {
+	filter := elasticOwnerFilter(ctx)
+	if filter == nil {
+		return query
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{"must": query, "filter": []interface{}{filter}},
 	}
 }
 
 type todoHits struct {
 	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
 		Hits []struct {
-			ID     string             `json:"_id"`
-			Source models.TodoElastic `json:"_source"`
+			ID        string              `json:"_id"`
+			Score     float64             `json:"_score"`
+			Source    elasticTodo         `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+			Sort      []interface{}       `json:"sort"`
 		} `json:"hits"`
 	} `json:"hits"`
+	Aggregations struct {
+		Completed struct {
+			Buckets []struct {
+				Key      string `json:"key_as_string"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"completed"`
+	} `json:"aggregations"`
+	Suggest map[string][]struct {
+		Options []struct {
+			Text string `json:"text"`
+		} `json:"options"`
+	} `json:"suggest"`
+}
+
+func (h *todoHits) todos() []models.Todo {
+	res := make([]models.Todo, len(h.Hits.Hits))
+	for i, hit := range h.Hits.Hits {
+		res[i] = hit.Source.toModel(hit.ID)
+	}
+	return res
 }
 
-func (e *ElasticSearch) CreateTodo(ctx context.Context, todo *models.TodoElastic) (string, error) {
+// CreateTodo waits for the next scheduled refresh, so the todo is listed and
+// searchable when it returns.
+func (e *ElasticSearch) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
 	// Generate unique ID
 	todo.ID = uuid.New().String()
 
 	// Create document in Elasticsearch
-	doc, err := json.Marshal(todo)
+	doc, err := json.Marshal(newElasticTodo(todo))
 	if err != nil {
 		return "", err
 	}
@@ -49,6 +182,7 @@ func (e *ElasticSearch) CreateTodo(ctx context.Context, todo *models.TodoElastic
 		todo.ID,
 		bytes.NewReader(doc),
 		e.client.Create.WithContext(ctx),
+		e.client.Create.WithRefresh(BulkRefreshWaitFor),
 	)
 	if err != nil {
 		return "", err
@@ -62,12 +196,16 @@ func (e *ElasticSearch) CreateTodo(ctx context.Context, todo *models.TodoElastic
 	return todo.ID, nil
 }
 
-func (e *ElasticSearch) GetTodoByID(ctx context.Context, id string) (*models.TodoElastic, error) {
+func (e *ElasticSearch) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
 	request := esapi.GetRequest{Index: e.index, DocumentID: id}
 	response, err := request.Do(ctx, e.client)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrTodoNotFound
+	}
 	if response.Status() != "200 OK" {
 		return nil, errors.New("ElasticSearch: " + response.Status())
 	}
@@ -75,50 +213,86 @@ func (e *ElasticSearch) GetTodoByID(ctx context.Context, id string) (*models.Tod
 	if err := json.NewDecoder(response.Body).Decode(&results); err != nil {
 		return nil, err
 	}
-	results.Source.ID = results.ID
-	return &results.Source, nil
+	todo := results.Source.toModel(results.ID)
+	if !InOwnerScope(ctx, todo.OwnerID) {
+		return nil, ErrTodoNotFound
+	}
+	return &todo, nil
 }
 
 type Result struct {
-	Source models.TodoElastic `json:"_source"`
-	ID     string             `json:"_id"`
+	Source elasticTodo `json:"_source"`
+	ID     string      `json:"_id"`
 }
 
-func (e *ElasticSearch) UpdateTodo(ctx context.Context, todo *models.TodoElastic) (string, error) {
+// UpdateTodo replaces the fields of the todo, which is first looked up to
+// check its owner; owners never change, so the check stays valid. Like
+// CreateTodo it waits for the next scheduled refresh instead of forcing one,
+// so the change is searchable when it returns.
+func (e *ElasticSearch) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	if ownerScope(ctx) != "" {
+		if _, err := e.GetTodoByID(ctx, todo.ID); err != nil {
+			return err
+		}
+	}
 	var buf bytes.Buffer
 
-	if err := json.NewEncoder(&buf).Encode(todo); err != nil {
-		return "", fmt.Errorf("ElasticSearch update: %w", err)
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"doc": newElasticTodo(todo)}); err != nil {
+		return fmt.Errorf("ElasticSearch update: %w", err)
 	}
 
-	req := esapi.IndexRequest{
+	req := esapi.UpdateRequest{
 		Index:      e.index,
 		Body:       &buf,
 		DocumentID: todo.ID,
-		Refresh:    "true",
+		Refresh:    BulkRefreshWaitFor,
 	}
 
 	resp, err := req.Do(ctx, e.client)
 	if err != nil {
-		return "", fmt.Errorf("ElasticSearch update: %w", err)
+		return fmt.Errorf("ElasticSearch update: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrTodoNotFound
+	}
 	if resp.IsError() {
-		return "", fmt.Errorf("ElasticSearch update: %w", err)
+		return fmt.Errorf("ElasticSearch update: %s", resp.Status())
 	}
-	return todo.ID, nil
+	return nil
 }
 
-func (e *ElasticSearch) GetTodos(ctx context.Context, page, limit int64) ([]models.TodoElastic, error) {
-	should := map[string]interface{}{
-		"match": map[string]interface{}{
-			"_index": e.index,
-		},
+func (e *ElasticSearch) CountTodos(ctx context.Context) (int64, error) {
+	var buf bytes.Buffer
+	query := ownedQuery(ctx, map[string]interface{}{"match_all": map[string]interface{}{}})
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"query": query}); err != nil {
+		return 0, err
 	}
+	req := esapi.CountRequest{Index: []string{e.index}, Body: &buf}
 
+	resp, err := req.Do(ctx, e.client)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return 0, fmt.Errorf("ElasticSearch count: %s", resp.Status())
+	}
+
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+func (e *ElasticSearch) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
 	query := map[string]interface{}{
-		"query": should,
+		"query": ownedQuery(ctx, map[string]interface{}{"match_all": map[string]interface{}{}}),
+		"sort":  todoSort(""),
 		"size":  limit,
 		"from":  (page - 1) * limit,
 	}
@@ -128,47 +302,360 @@ func (e *ElasticSearch) GetTodos(ctx context.Context, page, limit int64) ([]mode
 		return nil, err
 	}
 
-	res := make([]models.TodoElastic, len(hit.Hits.Hits))
-	for i, h := range hit.Hits.Hits {
-		res[i].ID = h.ID
-		res[i].Title = h.Source.Title
-		res[i].Completed = h.Source.Completed
+	return hit.todos(), nil
+}
+
+// ListTodos filters in the filter context of a bool query, so no scores are
+// computed, and pages with search_after, which is not bound by
+// index.max_result_window. The title filter is a case-insensitive wildcard
+// on title.keyword, which matches any substring, not just whole words.
+func (e *ElasticSearch) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	filters := []interface{}{}
+	if owner := elasticOwnerFilter(ctx); owner != nil {
+		filters = append(filters, owner)
+	}
+	if query.Done != nil {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"completed": *query.Done},
+		})
+	}
+	if query.Title != "" {
+		filters = append(filters, map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				"title.keyword": map[string]interface{}{
+					"value":            "*" + escapeWildcard(query.Title) + "*",
+					"case_insensitive": true,
+				},
+			},
+		})
+	}
+	filters = appendElasticRange(filters, "created_at", query.Created)
+	filters = appendElasticRange(filters, "due_at", query.Due)
+
+	order := "asc"
+	if query.Sort.Desc {
+		order = "desc"
+	}
+	sort := []interface{}{map[string]interface{}{"id": order}}
+	switch query.Sort.Field {
+	case models.SortByTitle:
+		sort = []interface{}{map[string]interface{}{"title.keyword": order}, map[string]interface{}{"id": order}}
+	case models.SortByCreatedAt:
+		sort = []interface{}{map[string]interface{}{"created_at": order}, map[string]interface{}{"id": order}}
+	}
+
+	body := map[string]interface{}{
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters}},
+		"sort":  sort,
+		"size":  query.Limit,
+	}
+	if query.After != nil {
+		switch query.Sort.Field {
+		case models.SortByTitle:
+			body["search_after"] = []interface{}{query.After.Title, query.After.ID}
+		case models.SortByCreatedAt:
+			if query.After.CreatedAt == nil {
+				return nil, ErrInvalidCursor
+			}
+			// Dates sort by their epoch milliseconds.
+			body["search_after"] = []interface{}{query.After.CreatedAt.UnixMilli(), query.After.ID}
+		default:
+			body["search_after"] = []interface{}{query.After.ID}
+		}
 	}
 
-	return res, nil
+	hit, err := e.DecodeTodo(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return hit.todos(), nil
 }
 
-func (e *ElasticSearch) SearchTodos(ctx context.Context, query string, page, limit int64) ([]models.TodoElastic, error) {
-	searchQuery := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match": map[string]interface{}{
-				"title": query,
+// elasticChildrenPage is the number of subtasks ChildTodos reads per
+// request; it is the default index.max_result_window.
+const elasticChildrenPage = 10000
+
+// ChildTodos pages with search_after on the id, so parents with more
+// subtasks than fit in one request are read completely.
+func (e *ElasticSearch) ChildTodos(ctx context.Context, parentIDs []string) ([]models.Todo, error) {
+	children := []models.Todo{}
+	if len(parentIDs) == 0 {
+		return children, nil
+	}
+	body := map[string]interface{}{
+		"query": ownedQuery(ctx, map[string]interface{}{
+			"terms": map[string]interface{}{"parent_id": parentIDs},
+		}),
+		"sort": todoSort(""),
+		"size": elasticChildrenPage,
+	}
+	for {
+		hit, err := e.DecodeTodo(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+		page := hit.todos()
+		children = append(children, page...)
+		if len(page) < elasticChildrenPage {
+			return children, nil
+		}
+		body["search_after"] = []interface{}{page[len(page)-1].ID}
+	}
+}
+
+// appendElasticRange adds a range filter on field unless bounds is open.
+func appendElasticRange(filters []interface{}, field string, bounds models.TimeRange) []interface{} {
+	if bounds.From == nil && bounds.To == nil {
+		return filters
+	}
+	r := map[string]interface{}{}
+	if bounds.From != nil {
+		r["gte"] = bounds.From.Format(time.RFC3339Nano)
+	}
+	if bounds.To != nil {
+		r["lt"] = bounds.To.Format(time.RFC3339Nano)
+	}
+	return append(filters, map[string]interface{}{"range": map[string]interface{}{field: r}})
+}
+
+// escapeWildcard escapes the operators of a wildcard query.
+var escapeWildcard = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace
+
+// SearchTodos matches the query against title with typo tolerance and
+// against the edge n-grams of title.prefix, so a partly typed word already
+// finds its todos. Along with the page of hits it returns the highlighted
+// title fragments, the completed/open facet over all matches and, for
+// callers not limited to an owner, term suggestions for misspelled words.
+// from/size paging stops at the
+// index.max_result_window of 10000 hits; SearchTodosAfter has no such limit.
+func (e *ElasticSearch) SearchTodos(ctx context.Context, query string, page, limit int64) (*models.TodoSearchResult, error) {
+	from := (page - 1) * limit
+	body := searchBody(ctx, query, limit)
+	body["from"] = from
+
+	hits, err := e.DecodeTodo(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := hits.searchResult()
+	if err != nil {
+		return nil, err
+	}
+	result.HasMore = from+int64(len(result.Hits)) < result.Total
+	return result, nil
+}
+
+// SearchTodosAfter pages with search_after on the sort values of the last
+// hit, which are what the cursors encode. One hit more than asked for is
+// fetched to tell whether another page follows.
+func (e *ElasticSearch) SearchTodosAfter(ctx context.Context, query, after string, limit int64) (*models.TodoSearchResult, error) {
+	body := searchBody(ctx, query, limit+1)
+	if after != "" {
+		values, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(todoSort(query)) {
+			return nil, ErrInvalidCursor
+		}
+		body["search_after"] = values
+	}
+
+	hits, err := e.DecodeTodo(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := hits.searchResult()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(result.Hits)) > limit {
+		result.Hits = result.Hits[:limit]
+		result.HasMore = true
+	}
+	return result, nil
+}
+
+// todoSort orders hits by relevance when there is a query and by id
+// otherwise. The id makes the order total, which search_after relies on.
+func todoSort(query string) []interface{} {
+	byID := map[string]interface{}{"id": "asc"}
+	if query == "" {
+		return []interface{}{byID}
+	}
+	return []interface{}{map[string]interface{}{"_score": "desc"}, byID}
+}
+
+// searchBody builds the request of SearchTodos and SearchTodosAfter within
+// the owner scope of ctx. An empty query matches every todo and asks for no
+// highlights or suggestions; suggestions are only asked for outside of an
+// owner scope.
+func searchBody(ctx context.Context, query string, size int64) map[string]interface{} {
+	body := map[string]interface{}{
+		"query": ownedQuery(ctx, map[string]interface{}{"match_all": map[string]interface{}{}}),
+		"aggs": map[string]interface{}{
+			"completed": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "completed"},
 			},
 		},
-		"size": limit,
-		"from": (page - 1) * limit,
+		"sort":             todoSort(query),
+		"track_total_hits": true,
+		"size":             size,
+	}
+	if query == "" {
+		return body
 	}
 
-	hit, err := e.DecodeTodo(ctx, searchQuery)
+	body["query"] = ownedQuery(ctx, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []interface{}{
+				map[string]interface{}{
+					"match": map[string]interface{}{
+						"title": map[string]interface{}{
+							"query":         query,
+							"fuzziness":     "AUTO",
+							"prefix_length": 1,
+						},
+					},
+				},
+				map[string]interface{}{
+					"match": map[string]interface{}{
+						"title.prefix": map[string]interface{}{
+							"query": query,
+							"boost": 0.5,
+						},
+					},
+				},
+			},
+			"minimum_should_match": 1,
+		},
+	})
+	body["highlight"] = map[string]interface{}{
+		"fields": map[string]interface{}{
+			"title":        map[string]interface{}{},
+			"title.prefix": map[string]interface{}{},
+		},
+	}
+	// The term suggester reads the words of the whole index, whatever the
+	// query filters on, so it would show a scoped caller the titles of
+	// other owners.
+	if elasticOwnerFilter(ctx) == nil {
+		body["suggest"] = map[string]interface{}{
+			"title": map[string]interface{}{
+				"text": query,
+				"term": map[string]interface{}{"field": "title"},
+			},
+		}
+	}
+	return body
+}
+
+func (h *todoHits) searchResult() (*models.TodoSearchResult, error) {
+	result := &models.TodoSearchResult{
+		Hits:        make([]models.TodoSearchHit, len(h.Hits.Hits)),
+		Total:       h.Hits.Total.Value,
+		Suggestions: []string{},
+	}
+	todos := h.todos()
+	for i, hit := range h.Hits.Hits {
+		// The fragments of title mark whole words; title.prefix only
+		// matches when the last word is still being typed.
+		highlights := hit.Highlight["title"]
+		if len(highlights) == 0 {
+			highlights = hit.Highlight["title.prefix"]
+		}
+		if highlights == nil {
+			highlights = []string{}
+		}
+		cursor, err := encodeCursor(hit.Sort)
+		if err != nil {
+			return nil, err
+		}
+		result.Hits[i] = models.TodoSearchHit{Todo: todos[i], Score: hit.Score, Highlights: highlights, Cursor: cursor}
+	}
+	for _, bucket := range h.Aggregations.Completed.Buckets {
+		if bucket.Key == "true" {
+			result.Completed = bucket.DocCount
+		} else {
+			result.NotCompleted = bucket.DocCount
+		}
+	}
+	seen := make(map[string]bool)
+	for _, term := range h.Suggest["title"] {
+		for _, option := range term.Options {
+			if !seen[option.Text] {
+				seen[option.Text] = true
+				result.Suggestions = append(result.Suggestions, option.Text)
+			}
+		}
+	}
+	return result, nil
+}
+
+// encodeCursor turns the sort values of a hit into an opaque cursor.
+func encodeCursor(values []interface{}) (string, error) {
+	raw, err := json.Marshal(values)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
+
+// SuggestTodos returns distinct titles in the owner scope of ctx whose words
+// start with the words of prefix, best matches first.
+func (e *ElasticSearch) SuggestTodos(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	suggestQuery := map[string]interface{}{
+		"query": ownedQuery(ctx, map[string]interface{}{
+			"match": map[string]interface{}{
+				"title.prefix": map[string]interface{}{
+					"query":    prefix,
+					"operator": "and",
+				},
+			},
+		}),
+		"collapse": map[string]interface{}{"field": "title.keyword"},
+		"_source":  []string{"title"},
+		"size":     limit,
 	}
 
-	res := make([]models.TodoElastic, len(hit.Hits.Hits))
-	for i, h := range hit.Hits.Hits {
-		res[i].ID = h.ID
-		res[i].Title = h.Source.Title
-		res[i].Completed = h.Source.Completed
+	hits, err := e.DecodeTodo(ctx, suggestQuery)
+	if err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	titles := make([]string, len(hits.Hits.Hits))
+	for i, hit := range hits.Hits.Hits {
+		titles[i] = hit.Source.Title
+	}
+	return titles, nil
 }
 
+// DeleteTodoByID checks the owner of the todo like UpdateTodo.
 func (e *ElasticSearch) DeleteTodoByID(ctx context.Context, id string) error {
+	if ownerScope(ctx) != "" {
+		if _, err := e.GetTodoByID(ctx, id); err != nil {
+			return err
+		}
+	}
 	req := esapi.DeleteRequest{
 		Index:      e.index,
 		DocumentID: id,
-		Refresh:    "true",
+		Refresh:    BulkRefreshWaitFor,
 	}
 
 	res, err := req.Do(ctx, e.client)
@@ -177,6 +664,9 @@ func (e *ElasticSearch) DeleteTodoByID(ctx context.Context, id string) error {
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotFound {
+		return ErrTodoNotFound
+	}
 	if res.IsError() {
 		return fmt.Errorf("ElasticSearch delete: %s", res.Status())
 	}
@@ -210,7 +700,7 @@ func (e *ElasticSearch) DecodeTodo(ctx context.Context, query map[string]interfa
 	}
 	defer resp.Body.Close()
 	if resp.IsError() {
-		return nil, err
+		return nil, fmt.Errorf("ElasticSearch search: %s", resp.String())
 	}
 	if resp.Status() != "200 OK" {
 		return nil, errors.New("ElasticSearch: " + resp.Status())
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"newFeatures/models"
+	"strconv"
 )
 
 type TodoMaria struct {
@@ -14,61 +16,127 @@ func NewTodoMaria(db *sql.DB) *TodoMaria {
 	return &TodoMaria{DB: db}
 }
 
-func (r *TodoMaria) CreateTodo(ctx context.Context, todo *models.TodoMaria) (int, error) {
-	result, err := r.DB.ExecContext(ctx, "INSERT INTO todos (title, completed) VALUES (?, ?)", todo.Title, todo.Completed)
+func (r *TodoMaria) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		INSERT INTO todos (owner_id, title, description, completed, due_at, priority, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		todo.OwnerID, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-	return int(id), nil
+	todo.ID = strconv.FormatInt(id, 10)
+	return todo.ID, nil
 }
 
-func (r *TodoMaria) UpdateTodo(ctx context.Context, todo *models.TodoMaria) error {
-	_, err := r.DB.ExecContext(ctx, "UPDATE todos SET title = ?, completed = ? WHERE id = ?", todo.Title, todo.Completed, todo.ID)
+func (r *TodoMaria) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	id, err := strconv.Atoi(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owned, args := ownerCondition(ctx, questionPlaceholder, []interface{}{
+		todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.UpdatedAt, id,
+	})
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE todos SET title = ?, description = ?, completed = ?, due_at = ?, priority = ?, updated_at = ?
+		WHERE id = ? AND `+owned, args...)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (r *TodoMaria) DeleteTodoByID(ctx context.Context, id int) error {
-	_, err := r.DB.ExecContext(ctx, "DELETE FROM todos WHERE id = ?", id)
+func (r *TodoMaria) DeleteTodoByID(ctx context.Context, id string) error {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owned, args := ownerCondition(ctx, questionPlaceholder, []interface{}{todoID})
+	result, err := r.DB.ExecContext(ctx, "DELETE FROM todos WHERE id = ? AND "+owned, args...)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
-func (r *TodoMaria) GetTodos(ctx context.Context, page int64, limit int64) ([]models.TodoMaria, error) {
-	offset := (page - 1) * limit
-	rows, err := r.DB.QueryContext(ctx, "SELECT id, title, completed FROM todos LIMIT ?, ?", offset, limit)
+func (r *TodoMaria) GetTodos(ctx context.Context, page int64, limit int64) ([]models.Todo, error) {
+	owned, args := ownerCondition(ctx, questionPlaceholder, nil)
+	args = append(args, (page-1)*limit, limit)
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+mariaTodoColumns+" FROM todos WHERE "+owned+" ORDER BY id LIMIT ?, ?", args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	todos := []models.TodoMaria{}
+	todos := []models.Todo{}
 	for rows.Next() {
-		var todo models.TodoMaria
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Completed)
+		var todo models.Todo
+		var id int
+		err := rows.Scan(todoFields(&todo, &id)...)
 		if err != nil {
 			return nil, err
 		}
+		todo.ID = strconv.Itoa(id)
 		todos = append(todos, todo)
 	}
 
-	return todos, nil
+	return todos, rows.Err()
+}
+
+var mariaTodoColumns = sqlTodoColumns("completed")
+
+// MariaDB compares case-insensitively under the default collation and
+// escapes LIKE wildcards with a backslash.
+var mariaTodoList = sqlTodoList{
+	placeholder: questionPlaceholder,
+	done:        "completed",
+	key:         intKeyValue,
+	id:          plainID,
+	contains: func(arg func(interface{}) string, value string) string {
+		return "title LIKE " + arg("%"+escapeLike(value)+"%")
+	},
+}
+
+func (r *TodoMaria) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	clauses, args, err := mariaTodoList.build(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.QueryContext(ctx, "SELECT "+mariaTodoColumns+" FROM todos"+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanNumericTodos(rows, todoFields)
+}
+
+func (r *TodoMaria) CountTodos(ctx context.Context) (int64, error) {
+	var count int64
+	owned, args := ownerCondition(ctx, questionPlaceholder, nil)
+	err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE "+owned, args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-func (r *TodoMaria) GetTodoByID(ctx context.Context, id int) (models.TodoMaria, error) {
-	row := r.DB.QueryRowContext(ctx, "SELECT id, title, completed FROM todos WHERE id = ?", id)
-	todo := models.TodoMaria{}
-	err := row.Scan(&todo.ID, &todo.Title, &todo.Completed)
+func (r *TodoMaria) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidTodoID
+	}
+	owned, args := ownerCondition(ctx, questionPlaceholder, []interface{}{todoID})
+	row := r.DB.QueryRowContext(ctx, "SELECT "+mariaTodoColumns+" FROM todos WHERE id = ? AND "+owned, args...)
+	todo := models.Todo{}
+	err = row.Scan(todoFields(&todo, &todoID)...)
 	if err != nil {
-		return models.TodoMaria{}, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
 	}
-	return todo, nil
+	todo.ID = strconv.Itoa(todoID)
+	return &todo, nil
 }
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"newFeatures/models"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// TodoMemory keeps todos in process memory. It is meant for local
+// development and tests; nothing survives a restart.
+type TodoMemory struct {
+	mu     sync.RWMutex
+	todos  map[int]models.Todo
+	nextID int
+}
+
+func NewTodoMemory() *TodoMemory {
+	return &TodoMemory{todos: make(map[int]models.Todo)}
+}
+
+func (r *TodoMemory) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidTodoID
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[todoID]
+	if !ok || !InOwnerScope(ctx, todo.OwnerID) {
+		return nil, ErrTodoNotFound
+	}
+	return &todo, nil
+}
+
+func (r *TodoMemory) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0, len(r.todos))
+	for id, todo := range r.todos {
+		if InOwnerScope(ctx, todo.OwnerID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	todos := []models.Todo{}
+	for i := (page - 1) * limit; i < int64(len(ids)) && int64(len(todos)) < limit; i++ {
+		todos = append(todos, r.todos[ids[i]])
+	}
+	return todos, nil
+}
+
+func (r *TodoMemory) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	var after int64
+	if query.After != nil {
+		var err error
+		if after, err = intKey(query.After.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.RLock()
+	todos := make([]models.Todo, 0, len(r.todos))
+	for _, todo := range r.todos {
+		if InOwnerScope(ctx, todo.OwnerID) && matchesTodo(query, todo) {
+			todos = append(todos, todo)
+		}
+	}
+	r.mu.RUnlock()
+
+	// less orders by the sort field and then by the numeric id; the ids of
+	// the memory store are integers.
+	less := func(a, b models.Todo) bool {
+		switch {
+		case query.Sort.Field == models.SortByTitle && a.Title != b.Title:
+			return a.Title < b.Title != query.Sort.Desc
+		case query.Sort.Field == models.SortByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt) != query.Sort.Desc
+		}
+		idA, _ := strconv.ParseInt(a.ID, 10, 64)
+		idB, _ := strconv.ParseInt(b.ID, 10, 64)
+		// Equal ids are not less in either direction, so the todo a cursor
+		// points at is not listed again.
+		return idA != idB && idA < idB != query.Sort.Desc
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return less(todos[i], todos[j])
+	})
+
+	// last stands for the todo the cursor points at.
+	var last models.Todo
+	if query.After != nil {
+		last = models.Todo{ID: strconv.FormatInt(after, 10), Title: query.After.Title}
+		if query.Sort.Field == models.SortByCreatedAt {
+			if query.After.CreatedAt == nil {
+				return nil, ErrInvalidCursor
+			}
+			last.CreatedAt = *query.After.CreatedAt
+		}
+	}
+	page := []models.Todo{}
+	for _, todo := range todos {
+		if int64(len(page)) == query.Limit {
+			break
+		}
+		if query.After == nil || less(last, todo) {
+			page = append(page, todo)
+		}
+	}
+	return page, nil
+}
+
+func (r *TodoMemory) CountTodos(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, todo := range r.todos {
+		if InOwnerScope(ctx, todo.OwnerID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *TodoMemory) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	todo.ID = strconv.Itoa(r.nextID)
+	r.todos[r.nextID] = *todo
+	return todo.ID, nil
+}
+
+func (r *TodoMemory) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	todoID, err := strconv.Atoi(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.todos[todoID]
+	if !ok || !InOwnerScope(ctx, current.OwnerID) {
+		return ErrTodoNotFound
+	}
+	todo.OwnerID = current.OwnerID
+	todo.ParentID = current.ParentID
+	todo.CreatedAt = current.CreatedAt
+	r.todos[todoID] = *todo
+	return nil
+}
+
+func (r *TodoMemory) ChildTodos(ctx context.Context, parentIDs []string) ([]models.Todo, error) {
+	parents := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		parents[id] = true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []int{}
+	for id, todo := range r.todos {
+		if todo.ParentID != "" && parents[todo.ParentID] && InOwnerScope(ctx, todo.OwnerID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	todos := make([]models.Todo, len(ids))
+	for i, id := range ids {
+		todos[i] = r.todos[id]
+	}
+	return todos, nil
+}
+
+func (r *TodoMemory) DeleteTodoByID(ctx context.Context, id string) error {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo, ok := r.todos[todoID]; !ok || !InOwnerScope(ctx, todo.OwnerID) {
+		return ErrTodoNotFound
+	}
+	delete(r.todos, todoID)
+	return nil
+}
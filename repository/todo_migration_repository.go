@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"newFeatures/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// MigrationMetrics counts what the migration store observes on the secondary.
+type MigrationMetrics struct {
+	SecondaryErrors *prometheus.CounterVec
+	ShadowReads     *prometheus.CounterVec
+	Mismatches      *prometheus.CounterVec
+}
+
+func NewMigrationMetrics(reg prometheus.Registerer) *MigrationMetrics {
+	m := &MigrationMetrics{
+		SecondaryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "todo_service_migration_secondary_errors_total",
+			Help: "The total number of failed operations on the secondary store",
+		}, []string{"primary", "secondary", "operation"}),
+		ShadowReads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "todo_service_migration_shadow_reads_total",
+			Help: "The total number of reads compared against the secondary store",
+		}, []string{"primary", "secondary", "operation"}),
+		Mismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "todo_service_migration_mismatches_total",
+			Help: "The total number of reads where the secondary store disagreed with the primary",
+		}, []string{"primary", "secondary", "operation"}),
+	}
+	reg.MustRegister(
+		m.SecondaryErrors,
+		m.ShadowReads,
+		m.Mismatches,
+	)
+	return m
+}
+
+// TodoMigration is a TodoStore used while moving a dataset from one backend
+// to another. Every write goes to the primary first and is then replayed on
+// the secondary; reads are always served by the primary and, when shadow
+// reads are enabled, compared against the secondary. Failures on the
+// secondary are logged and counted but never fail the request, so the
+// primary stays the source of truth until CURRENT_DB is switched over.
+type TodoMigration struct {
+	primary       TodoStore
+	secondary     TodoStore
+	primaryName   string
+	secondaryName string
+	shadowReads   bool
+	metrics       *MigrationMetrics
+
+	// Backends generate their own ids, so a todo gets a different id on
+	// each side. ids maps primary ids to secondary ids for the todos
+	// created through the wrapper or copied by cmd/transfer.
+	ids *MigrationIDMap
+}
+
+func NewTodoMigration(primary, secondary TodoStore, primaryName, secondaryName string, shadowReads bool, metrics *MigrationMetrics, ids *MigrationIDMap) *TodoMigration {
+	return &TodoMigration{
+		primary:       primary,
+		secondary:     secondary,
+		primaryName:   primaryName,
+		secondaryName: secondaryName,
+		shadowReads:   shadowReads,
+		metrics:       metrics,
+		ids:           ids,
+	}
+}
+
+// secondaryID returns the secondary id of a todo. A todo without one is
+// counted as a mismatch of operation: the secondary would have to be
+// written by the primary id, which may name an unrelated todo there.
+func (r *TodoMigration) secondaryID(operation, id string) (string, bool) {
+	secondaryID, ok := r.ids.Lookup(id)
+	if !ok {
+		r.mismatch(operation, "todo %s has no secondary id, the secondary is skipped", id)
+	}
+	return secondaryID, ok
+}
+
+func (r *TodoMigration) secondaryFailed(operation string, err error) {
+	logrus.Errorf("TodoMigration %s (%s -> %s): secondary error: %s", operation, r.primaryName, r.secondaryName, err)
+	r.metrics.SecondaryErrors.WithLabelValues(r.primaryName, r.secondaryName, operation).Inc()
+}
+
+func (r *TodoMigration) mismatch(operation, format string, args ...interface{}) {
+	logrus.Warnf("TodoMigration %s (%s -> %s): "+format, append([]interface{}{operation, r.primaryName, r.secondaryName}, args...)...)
+	r.metrics.Mismatches.WithLabelValues(r.primaryName, r.secondaryName, operation).Inc()
+}
+
+func (r *TodoMigration) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todo, err := r.primary.GetTodoByID(ctx, id)
+	// Todos missing on the primary have no secondary id to compare by.
+	if !r.shadowReads || err != nil {
+		return todo, err
+	}
+	secondaryID, ok := r.secondaryID("get", id)
+	if !ok {
+		return todo, nil
+	}
+	r.metrics.ShadowReads.WithLabelValues(r.primaryName, r.secondaryName, "get").Inc()
+	shadow, shadowErr := r.secondary.GetTodoByID(ctx, secondaryID)
+	switch {
+	case shadowErr != nil && !errors.Is(shadowErr, ErrTodoNotFound) && !errors.Is(shadowErr, ErrInvalidTodoID):
+		r.secondaryFailed("get", shadowErr)
+	case shadowErr != nil:
+		r.mismatch("get", "todo %s is missing on the secondary", id)
+	case !sameTodo(*todo, *shadow):
+		r.mismatch("get", "todo %s differs: primary %+v, secondary %+v", id, *todo, *shadow)
+	}
+	return todo, nil
+}
+
+// sameTodo compares the owner and the fields clients set. The timestamps
+// are left out: each backend stamped its existing todos when it was
+// migrated.
+func sameTodo(a, b models.Todo) bool {
+	sameDue := a.DueAt == nil && b.DueAt == nil || a.DueAt != nil && b.DueAt != nil && a.DueAt.Equal(*b.DueAt)
+	return a.OwnerID == b.OwnerID && a.Title == b.Title && a.Description == b.Description && a.Done == b.Done && a.Priority == b.Priority && sameDue
+}
+
+// GetTodos compares only the page size: the backends order their ids
+// differently, so the same page holds different todos on each side.
+func (r *TodoMigration) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	todos, err := r.primary.GetTodos(ctx, page, limit)
+	if err != nil || !r.shadowReads {
+		return todos, err
+	}
+
+	r.metrics.ShadowReads.WithLabelValues(r.primaryName, r.secondaryName, "list").Inc()
+	shadow, err := r.secondary.GetTodos(ctx, page, limit)
+	if err != nil {
+		r.secondaryFailed("list", err)
+		return todos, nil
+	}
+	if len(todos) != len(shadow) {
+		r.mismatch("list", "page %d has %d todos on the primary and %d on the secondary", page, len(todos), len(shadow))
+	}
+	return todos, nil
+}
+
+func (r *TodoMigration) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	todos, err := r.primary.ListTodos(ctx, query)
+	if err != nil || !r.shadowReads {
+		return todos, err
+	}
+
+	// Only first pages can be compared: the cursor holds a primary id,
+	// which the secondary orders differently or does not know at all.
+	if query.After != nil {
+		return todos, nil
+	}
+	r.metrics.ShadowReads.WithLabelValues(r.primaryName, r.secondaryName, "list").Inc()
+	shadow, err := r.secondary.ListTodos(ctx, query)
+	if err != nil {
+		r.secondaryFailed("list", err)
+		return todos, nil
+	}
+	if len(todos) != len(shadow) {
+		r.mismatch("list", "first page has %d todos on the primary and %d on the secondary", len(todos), len(shadow))
+	}
+	return todos, nil
+}
+
+func (r *TodoMigration) CountTodos(ctx context.Context) (int64, error) {
+	count, err := r.primary.CountTodos(ctx)
+	if err != nil || !r.shadowReads {
+		return count, err
+	}
+
+	r.metrics.ShadowReads.WithLabelValues(r.primaryName, r.secondaryName, "count").Inc()
+	shadow, err := r.secondary.CountTodos(ctx)
+	if err != nil {
+		r.secondaryFailed("count", err)
+		return count, nil
+	}
+	if count != shadow {
+		r.mismatch("count", "primary has %d todos, secondary has %d", count, shadow)
+	}
+	return count, nil
+}
+
+func (r *TodoMigration) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	id, err := r.primary.CreateTodo(ctx, todo)
+	if err != nil {
+		return "", err
+	}
+	shadow := *todo
+	shadow.ID = id
+	r.mirrorCreate(ctx, "create", shadow)
+	return id, nil
+}
+
+func (r *TodoMigration) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	if err := r.primary.UpdateTodo(ctx, todo); err != nil {
+		return err
+	}
+	r.mirrorUpdate(ctx, "update", *todo)
+	return nil
+}
+
+func (r *TodoMigration) DeleteTodoByID(ctx context.Context, id string) error {
+	if err := r.primary.DeleteTodoByID(ctx, id); err != nil {
+		return err
+	}
+	r.mirrorDelete(ctx, "delete", id)
+	return nil
+}
+
+// mirrorCreate creates the copy of a todo the primary stored under todo.ID
+// on the secondary. Like cmd/transfer it puts the copy in no list, as lists
+// are not mirrored, and points a subtask at the copy of its parent; a
+// subtask whose parent has no copy is not mirrored.
+func (r *TodoMigration) mirrorCreate(ctx context.Context, operation string, todo models.Todo) {
+	id := todo.ID
+	todo.ID = ""
+	todo.ListID = ""
+	if todo.ParentID != "" {
+		parentID, ok := r.secondaryID(operation, todo.ParentID)
+		if !ok {
+			return
+		}
+		todo.ParentID = parentID
+	}
+	secondaryID, err := r.secondary.CreateTodo(ctx, &todo)
+	if err != nil {
+		r.secondaryFailed(operation, err)
+		return
+	}
+	if err := r.ids.Store(id, secondaryID); err != nil {
+		r.secondaryFailed(operation, fmt.Errorf("recording secondary id %s of todo %s: %w", secondaryID, id, err))
+	}
+}
+
+func (r *TodoMigration) mirrorUpdate(ctx context.Context, operation string, todo models.Todo) {
+	secondaryID, ok := r.secondaryID(operation, todo.ID)
+	if !ok {
+		return
+	}
+	todo.ID = secondaryID
+	if err := r.secondary.UpdateTodo(ctx, &todo); err != nil {
+		r.secondaryFailed(operation, err)
+	}
+}
+
+func (r *TodoMigration) mirrorDelete(ctx context.Context, operation, id string) {
+	secondaryID, ok := r.secondaryID(operation, id)
+	if !ok {
+		return
+	}
+	if err := r.secondary.DeleteTodoByID(ctx, secondaryID); err != nil && !errors.Is(err, ErrTodoNotFound) {
+		r.secondaryFailed(operation, err)
+	}
+	if err := r.ids.Delete(id); err != nil {
+		r.secondaryFailed(operation, fmt.Errorf("forgetting secondary id of todo %s: %w", id, err))
+	}
+}
+
+// TodoMigrationLists is the TodoLists of a primary in migration mode. Lists
+// are not mirrored, just like cmd/transfer does not copy them, so only the
+// todos that DeleteList deletes along with a list are deleted on the
+// secondary; moving a todo changes nothing the secondary keeps.
+type TodoMigrationLists struct {
+	TodoLists
+	todos *TodoMigration
+}
+
+func NewTodoMigrationLists(lists TodoLists, todos *TodoMigration) *TodoMigrationLists {
+	return &TodoMigrationLists{TodoLists: lists, todos: todos}
+}
+
+func (l *TodoMigrationLists) DeleteList(ctx context.Context, id string) ([]string, error) {
+	ids, err := l.TodoLists.DeleteList(ctx, id)
+	if err != nil {
+		return ids, err
+	}
+	for _, todoID := range ids {
+		l.todos.mirrorDelete(ctx, "delete_list", todoID)
+	}
+	return ids, nil
+}
+
+// TodoMigrationBulk is the TodoBulk of a primary in migration mode. The
+// todos the primary accepted are written to the secondary one by one, which
+// makes bulk writes slower while a migration runs.
+type TodoMigrationBulk struct {
+	primary TodoBulk
+	todos   *TodoMigration
+}
+
+func NewTodoMigrationBulk(primary TodoBulk, todos *TodoMigration) *TodoMigrationBulk {
+	return &TodoMigrationBulk{primary: primary, todos: todos}
+}
+
+func (b *TodoMigrationBulk) BulkCreateTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error) {
+	results, err := b.primary.BulkCreateTodos(ctx, todos, refresh)
+	if err != nil {
+		return results, err
+	}
+	for i, result := range results {
+		if result.Error == "" {
+			todo := todos[i]
+			todo.ID = result.ID
+			b.todos.mirrorCreate(ctx, "bulk_create", todo)
+		}
+	}
+	return results, nil
+}
+
+// BulkIndexTodos updates the copies of the todos that have one and creates
+// the others.
+func (b *TodoMigrationBulk) BulkIndexTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error) {
+	results, err := b.primary.BulkIndexTodos(ctx, todos, refresh)
+	if err != nil {
+		return results, err
+	}
+	for i, result := range results {
+		if result.Error != "" {
+			continue
+		}
+		if _, ok := b.todos.ids.Lookup(todos[i].ID); ok {
+			b.todos.mirrorUpdate(ctx, "bulk_index", todos[i])
+		} else {
+			b.todos.mirrorCreate(ctx, "bulk_index", todos[i])
+		}
+	}
+	return results, nil
+}
+
+func (b *TodoMigrationBulk) BulkDeleteTodos(ctx context.Context, ids []string, refresh string) ([]models.BulkTodoResult, error) {
+	results, err := b.primary.BulkDeleteTodos(ctx, ids, refresh)
+	if err != nil {
+		return results, err
+	}
+	for i, result := range results {
+		if result.Error == "" {
+			b.todos.mirrorDelete(ctx, "bulk_delete", ids[i])
+		}
+	}
+	return results, nil
+}
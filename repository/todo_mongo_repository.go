@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"newFeatures/models"
+	"regexp"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,98 +14,242 @@ import (
 )
 
 type TodoMongo struct {
-	db *mongo.Client
+	collection *mongo.Collection
 }
 
-func NewTodoMongo(db *mongo.Client) *TodoMongo {
-	return &TodoMongo{db: db}
+// mongoTodo is the shape of a todo stored in the todos collection.
+type mongoTodo struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	OwnerID     string             `bson:"owner_id"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description"`
+	Done        bool               `bson:"done"`
+	DueAt       *time.Time         `bson:"due_at"`
+	Priority    int                `bson:"priority"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
 }
 
-func (r *TodoMongo) GetTodoByID(id primitive.ObjectID) (*models.TodoMongo, error) {
-	var todo models.TodoMongo
-	filter := bson.M{"_id": id}
-	collection := r.db.Database("mydb").Collection("todos")
-	err := collection.FindOne(context.Background(), filter).Decode(&todo)
+func newMongoTodo(todo *models.Todo) mongoTodo {
+	return mongoTodo{
+		OwnerID:     todo.OwnerID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Done:        todo.Done,
+		DueAt:       todo.DueAt,
+		Priority:    todo.Priority,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+func (d mongoTodo) toModel() models.Todo {
+	return models.Todo{
+		ID:          d.ID.Hex(),
+		OwnerID:     d.OwnerID,
+		Title:       d.Title,
+		Description: d.Description,
+		Done:        d.Done,
+		DueAt:       d.DueAt,
+		Priority:    d.Priority,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+// mongoTimeRange returns the filter of bounds, nil if it is open.
+func mongoTimeRange(bounds models.TimeRange) bson.M {
+	if bounds.From == nil && bounds.To == nil {
+		return nil
+	}
+	filter := bson.M{}
+	if bounds.From != nil {
+		filter["$gte"] = *bounds.From
+	}
+	if bounds.To != nil {
+		filter["$lt"] = *bounds.To
+	}
+	return filter
+}
+
+// ownedFilter confines filter to the owner scope of ctx and returns it.
+func ownedFilter(ctx context.Context, filter bson.M) bson.M {
+	if owner := ownerScope(ctx); owner != "" {
+		filter["owner_id"] = owner
+	}
+	return filter
+}
+
+func NewTodoMongo(collection *mongo.Collection) *TodoMongo {
+	return &TodoMongo{collection: collection}
+}
+
+func (r *TodoMongo) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidTodoID
+	}
+	var doc mongoTodo
+	filter := ownedFilter(ctx, bson.M{"_id": objID})
+	err = r.collection.FindOne(ctx, filter).Decode(&doc)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("GetTodoByID: document not found:%w", err)
+			return nil, ErrTodoNotFound
 		}
 		return nil, fmt.Errorf("GetTodoByID: repository error:%w", err)
 	}
+	todo := doc.toModel()
 	return &todo, nil
 }
 
-func (r *TodoMongo) GetTodos(page, limit int64) ([]models.TodoMongo, int, error) {
-	var Todos []models.TodoMongo
-	filter := bson.M{}
-	collection := r.db.Database("mydb").Collection("todos")
-	findOptions := options.Find()
-	if page != 0 && limit != 0 {
-		findOptions.SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
-	}
-	cur, err := collection.Find(context.Background(), filter, findOptions)
+func (r *TodoMongo) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	Todos := []models.Todo{}
+	filter := ownedFilter(ctx, bson.M{})
+	findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cur, err := r.collection.Find(ctx, filter, findOptions)
 	if err != nil {
-		return nil, 0, fmt.Errorf("GetTodos: repository error:%w", err)
+		return nil, fmt.Errorf("GetTodos: repository error:%w", err)
 	}
-	for cur.Next(context.Background()) {
-		var Todo models.TodoMongo
-		err := cur.Decode(&Todo)
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc mongoTodo
+		err := cur.Decode(&doc)
 		if err != nil {
-			return nil, 0, fmt.Errorf("GetTodos: error while decoding todo:%w", err)
+			return nil, fmt.Errorf("GetTodos: error while decoding todo:%w", err)
 		}
-		Todos = append(Todos, Todo)
+		Todos = append(Todos, doc.toModel())
 	}
 	if err := cur.Err(); err != nil {
-		return nil, 0, fmt.Errorf("GetTodos: error during cursor iteration:%w", err)
+		return nil, fmt.Errorf("GetTodos: error during cursor iteration:%w", err)
+	}
+	return Todos, nil
+}
+
+func (r *TodoMongo) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	filter := ownedFilter(ctx, bson.M{})
+	if query.Done != nil {
+		filter["done"] = *query.Done
+	}
+	if query.Title != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(query.Title), Options: "i"}
+	}
+	if created := mongoTimeRange(query.Created); created != nil {
+		filter["created_at"] = created
 	}
-	cur.Close(context.Background())
-	count, err := collection.CountDocuments(context.Background(), filter)
+	if due := mongoTimeRange(query.Due); due != nil {
+		filter["due_at"] = due
+	}
+
+	direction, op := 1, "$gt"
+	if query.Sort.Desc {
+		direction, op = -1, "$lt"
+	}
+	if query.After != nil {
+		afterID, err := primitive.ObjectIDFromHex(query.After.ID)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		switch query.Sort.Field {
+		case models.SortByTitle:
+			filter["$or"] = bson.A{
+				bson.M{"title": bson.M{op: query.After.Title}},
+				bson.M{"title": query.After.Title, "_id": bson.M{op: afterID}},
+			}
+		case models.SortByCreatedAt:
+			if query.After.CreatedAt == nil {
+				return nil, ErrInvalidCursor
+			}
+			filter["$or"] = bson.A{
+				bson.M{"created_at": bson.M{op: *query.After.CreatedAt}},
+				bson.M{"created_at": *query.After.CreatedAt, "_id": bson.M{op: afterID}},
+			}
+		default:
+			filter["_id"] = bson.M{op: afterID}
+		}
+	}
+	sort := bson.D{{Key: "_id", Value: direction}}
+	switch query.Sort.Field {
+	case models.SortByTitle:
+		sort = bson.D{{Key: "title", Value: direction}, {Key: "_id", Value: direction}}
+	case models.SortByCreatedAt:
+		sort = bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}
+	}
+
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetSort(sort).SetLimit(query.Limit))
 	if err != nil {
-		return nil, 0, fmt.Errorf("GetTodos: error while getting count of documents:%w", err)
+		return nil, fmt.Errorf("ListTodos: repository error:%w", err)
+	}
+	defer cur.Close(ctx)
+
+	todos := []models.Todo{}
+	for cur.Next(ctx) {
+		var doc mongoTodo
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("ListTodos: error while decoding todo:%w", err)
+		}
+		todos = append(todos, doc.toModel())
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("ListTodos: error during cursor iteration:%w", err)
 	}
-	return Todos, int(count), nil
+	return todos, nil
 }
 
-func (r *TodoMongo) CreateTodo(todo *models.TodoMongo) (string, error) {
-	collection := r.db.Database("mydb").Collection("todos")
-	result, err := collection.InsertOne(context.Background(), todo)
+func (r *TodoMongo) CountTodos(ctx context.Context) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, ownedFilter(ctx, bson.M{}))
 	if err != nil {
-		return "", fmt.Errorf("CreateTodo: repository error:%w", err)
+		return 0, fmt.Errorf("CountTodos: error while getting count of documents:%w", err)
 	}
-	idStr := result.InsertedID.(primitive.ObjectID).Hex()
+	return count, nil
+}
 
+func (r *TodoMongo) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	result, err := r.collection.InsertOne(ctx, newMongoTodo(todo))
 	if err != nil {
-		return "", fmt.Errorf("CreateTodo: error converting id to int: %w", err)
+		return "", fmt.Errorf("CreateTodo: repository error:%w", err)
 	}
-	return idStr, nil
+	todo.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return todo.ID, nil
 }
 
-func (r *TodoMongo) UpdateTodo(todo *models.TodoMongo) error {
-	filter := bson.M{"_id": todo.ID}
-	collection := r.db.Database("mydb").Collection("todos")
+func (r *TodoMongo) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	objID, err := primitive.ObjectIDFromHex(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	filter := ownedFilter(ctx, bson.M{"_id": objID})
 	update := bson.M{
 		"$set": bson.M{
-			"title": todo.Title,
-			"done":  todo.Done,
+			"title":       todo.Title,
+			"description": todo.Description,
+			"done":        todo.Done,
+			"due_at":      todo.DueAt,
+			"priority":    todo.Priority,
+			"updated_at":  todo.UpdatedAt,
 		},
 	}
-	_, err := collection.UpdateOne(context.Background(), filter, update)
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("UpdateTodo: repository error:%w", err)
 	}
+	if result.MatchedCount == 0 {
+		return ErrTodoNotFound
+	}
 	return nil
 }
 
-func (r *TodoMongo) DeleteTodoByID(id primitive.ObjectID) (string, error) {
-	filter := bson.M{"_id": id}
-	collection := r.db.Database("mydb").Collection("todos")
-	var Todo models.TodoMongo
-	err := collection.FindOneAndDelete(context.Background(), filter).Decode(&Todo)
+func (r *TodoMongo) DeleteTodoByID(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return "", fmt.Errorf("DeleteTodoByID: document not found")
-		}
-		return "", fmt.Errorf("DeleteTodoByID: repository error:%w", err)
+		return ErrInvalidTodoID
+	}
+	filter := ownedFilter(ctx, bson.M{"_id": objID})
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("DeleteTodoByID: repository error:%w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrTodoNotFound
 	}
-	return Todo.ID.Hex(), nil
+	return nil
 }
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"newFeatures/models"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// TodoChangesChannel is the channel the todos trigger notifies on.
+const TodoChangesChannel = "todo_changes"
+
+// TodoChange records that the todo with TodoID was inserted, updated or
+// deleted. Only the id is kept; consumers read the current row.
+type TodoChange struct {
+	Seq    int64
+	TodoID string
+}
+
+// TodoChanges reads the change log that a trigger on todos fills, see the
+// 0003_todo_changes migration. The trigger is installed by Capture only, so
+// deployments without a consumer pay nothing for it. The log is a queue:
+// consumers remove the changes they have applied with Ack, so whatever is
+// left is exactly what still has to be synced, including changes whose
+// transaction committed after changes with a higher Seq.
+type TodoChanges struct {
+	db *sql.DB
+}
+
+func NewTodoChanges(db *sql.DB) *TodoChanges {
+	return &TodoChanges{db: db}
+}
+
+// Capture installs the trigger that logs the changes of todos, unless it is
+// installed already, and reports whether it was. Changes made before it was
+// installed are not in the log.
+func (c *TodoChanges) Capture(ctx context.Context) (bool, error) {
+	var installed bool
+	err := c.db.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM pg_trigger WHERE tgrelid = 'todos'::regclass AND tgname = 'todos_record_change'
+	)`).Scan(&installed)
+	if err != nil {
+		return false, fmt.Errorf("Capture: repository error:%w", err)
+	}
+	if installed {
+		return false, nil
+	}
+	_, err = c.db.ExecContext(ctx, `CREATE TRIGGER todos_record_change
+		AFTER INSERT OR UPDATE OR DELETE ON todos
+		FOR EACH ROW EXECUTE PROCEDURE record_todo_change()`)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "duplicate_object" {
+		// Another syncer installed it in the meantime.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Capture: repository error:%w", err)
+	}
+	return true, nil
+}
+
+// StopCapture removes the trigger and empties the log.
+func (c *TodoChanges) StopCapture(ctx context.Context) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("StopCapture: repository error:%w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DROP TRIGGER IF EXISTS todos_record_change ON todos"); err != nil {
+		return fmt.Errorf("StopCapture: repository error:%w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM todo_changes"); err != nil {
+		return fmt.Errorf("StopCapture: repository error:%w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("StopCapture: repository error:%w", err)
+	}
+	return nil
+}
+
+// Pending returns up to limit unacknowledged changes, oldest first.
+func (c *TodoChanges) Pending(ctx context.Context, limit int64) ([]TodoChange, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT seq, todo_id FROM todo_changes ORDER BY seq LIMIT $1", limit)
+	if err != nil {
+		return nil, fmt.Errorf("Pending: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	changes := []TodoChange{}
+	for rows.Next() {
+		var change TodoChange
+		var todoID int
+		if err := rows.Scan(&change.Seq, &todoID); err != nil {
+			return nil, fmt.Errorf("Pending: repository error:%w", err)
+		}
+		change.TodoID = strconv.Itoa(todoID)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// Ack removes applied changes from the log.
+func (c *TodoChanges) Ack(ctx context.Context, seqs []int64) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+	if _, err := c.db.ExecContext(ctx, "DELETE FROM todo_changes WHERE seq = ANY($1)", pq.Array(seqs)); err != nil {
+		return fmt.Errorf("Ack: repository error:%w", err)
+	}
+	return nil
+}
+
+// Logged returns the Seq of every change in the log. A change whose
+// transaction has not committed yet is not among them, even if its Seq is
+// lower than one that is.
+func (c *TodoChanges) Logged(ctx context.Context) ([]int64, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT seq FROM todo_changes ORDER BY seq")
+	if err != nil {
+		return nil, fmt.Errorf("Logged: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	seqs := []int64{}
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, fmt.Errorf("Logged: repository error:%w", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, rows.Err()
+}
+
+// TodosByID returns the todos among ids that still exist. Ids that are not
+// numbers, such as those of todos created in another backend, never exist.
+func (c *TodoChanges) TodosByID(ctx context.Context, ids []string) ([]models.Todo, error) {
+	todoIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			todoIDs = append(todoIDs, n)
+		}
+	}
+	if len(todoIDs) == 0 {
+		return []models.Todo{}, nil
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT "+postgresTodoColumns+" FROM todos WHERE id = ANY($1)", pq.Array(todoIDs))
+	if err != nil {
+		return nil, fmt.Errorf("TodosByID: repository error:%w", err)
+	}
+	return scanNumericTodos(rows, postgresTodoFields)
+}
+
+// TodosAfter returns up to limit todos with an id above afterID, by id.
+func (c *TodoChanges) TodosAfter(ctx context.Context, afterID, limit int64) ([]models.Todo, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT "+postgresTodoColumns+" FROM todos WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("TodosAfter: repository error:%w", err)
+	}
+	return scanNumericTodos(rows, postgresTodoFields)
+}
@@ -0,0 +1,481 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"newFeatures/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// listRolesIncluding lists, for an SQL IN, the roles that include role.
+func listRolesIncluding(role models.ListRole) string {
+	var roles []string
+	for _, r := range []models.ListRole{models.ListViewer, models.ListEditor, models.ListOwner} {
+		if r.Includes(role) {
+			roles = append(roles, "'"+string(r)+"'")
+		}
+	}
+	return strings.Join(roles, ", ")
+}
+
+// sharedLists returns the SQL condition that the list whose id is column is
+// shared with the user bound to param with role or a role including it.
+func sharedLists(column, param string, role models.ListRole) string {
+	return fmt.Sprintf("%s IN (SELECT list_id FROM list_members WHERE user_id = %s AND role IN (%s))",
+		column, param, listRolesIncluding(role))
+}
+
+// sharedTodos returns the SQL condition that the user bound to param may
+// reach a todo as role: the todos in lists through the list members, the
+// others through their owner.
+func sharedTodos(param string, role models.ListRole) string {
+	return fmt.Sprintf("((list_id IS NULL AND owner_id = %s) OR %s)", param, sharedLists("list_id", param, role))
+}
+
+// listAccess is ownerCondition for the lists, see sharedLists.
+func listAccess(ctx context.Context, column string, role models.ListRole, args []interface{}) (string, []interface{}) {
+	owner := ownerScope(ctx)
+	if owner == "" {
+		return "TRUE", args
+	}
+	args = append(args, owner)
+	return sharedLists(column, dollarPlaceholder(len(args)), role), args
+}
+
+// todoAccess is ownerCondition for the backends with lists, see
+// sharedTodos.
+func todoAccess(ctx context.Context, role models.ListRole, args []interface{}) (string, []interface{}) {
+	owner := ownerScope(ctx)
+	if owner == "" {
+		return "TRUE", args
+	}
+	args = append(args, owner)
+	return sharedTodos(dollarPlaceholder(len(args)), role), args
+}
+
+// todoListColumns reads a row of todo_lists, see the todo_lists migrations,
+// with the number of its todos and of its completed todos.
+const todoListColumns = `id, owner_id, name, archived, created_at, updated_at,
+	(SELECT COUNT(*) FROM todos WHERE todos.list_id = todo_lists.id),
+	(SELECT COUNT(*) FROM todos WHERE todos.list_id = todo_lists.id AND todos.done)`
+
+func todoListFields(list *models.TodoList, id *int64) []interface{} {
+	return []interface{}{id, &list.OwnerID, &list.Name, &list.Archived, &list.CreatedAt, &list.UpdatedAt, &list.TodoCount, &list.DoneCount}
+}
+
+// CreateList makes the owner of the list the first of its members.
+func (u *TodoPostgres) CreateList(ctx context.Context, list *models.TodoList) (string, error) {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("CreateList: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO todo_lists (owner_id, name, archived, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		list.OwnerID, list.Name, list.Archived, list.CreatedAt, list.UpdatedAt)
+	if err := row.Scan(&id); err != nil {
+		logrus.Errorf("CreateList: error while scanning for list:%s", err)
+		return "", fmt.Errorf("CreateList: repository error:%w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO list_members (list_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+		id, list.OwnerID, models.ListOwner, list.CreatedAt); err != nil {
+		logrus.Errorf("CreateList: error while adding the owner:%s", err)
+		return "", fmt.Errorf("CreateList: repository error:%w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("CreateList: repository error:%w", err)
+	}
+	list.ID = strconv.FormatInt(id, 10)
+	return list.ID, nil
+}
+
+func (u *TodoPostgres) GetList(ctx context.Context, id string) (*models.TodoList, error) {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidListID
+	}
+	var list models.TodoList
+	owned, args := listAccess(ctx, "id", models.ListViewer, []interface{}{listID})
+	row := u.db.QueryRowContext(ctx, "SELECT "+todoListColumns+" FROM todo_lists WHERE id = $1 AND "+owned, args...)
+	if err := row.Scan(todoListFields(&list, &listID)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrListNotFound
+		}
+		logrus.Errorf("GetList: error while scanning for list:%s", err)
+		return nil, fmt.Errorf("GetList: repository error:%w", err)
+	}
+	list.ID = strconv.FormatInt(listID, 10)
+	return &list, nil
+}
+
+func (u *TodoPostgres) Lists(ctx context.Context, query models.TodoListQuery) ([]models.TodoList, error) {
+	afterID, err := intKey(query.AfterID)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite numbers the placeholders in the order they appear, so the
+	// limit is bound after the owner.
+	owned, args := listAccess(ctx, "id", models.ListViewer, []interface{}{query.Archived, afterID})
+	args = append(args, query.Limit)
+	rows, err := u.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM todo_lists WHERE archived = $1 AND id > $2 AND %s ORDER BY id LIMIT $%d",
+		todoListColumns, owned, len(args)), args...)
+	if err != nil {
+		logrus.Errorf("Lists: can not executes a query:%s", err)
+		return nil, fmt.Errorf("Lists: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	lists := []models.TodoList{}
+	for rows.Next() {
+		var list models.TodoList
+		var id int64
+		if err := rows.Scan(todoListFields(&list, &id)...); err != nil {
+			return nil, fmt.Errorf("Lists: repository error:%w", err)
+		}
+		list.ID = strconv.FormatInt(id, 10)
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+func (u *TodoPostgres) UpdateList(ctx context.Context, list *models.TodoList) error {
+	listID, err := strconv.ParseInt(list.ID, 10, 64)
+	if err != nil {
+		return ErrInvalidListID
+	}
+	owned, args := listAccess(ctx, "id", models.ListOwner, []interface{}{list.Name, list.Archived, list.UpdatedAt, listID})
+	result, err := u.db.ExecContext(ctx,
+		"UPDATE todo_lists SET name = $1, archived = $2, updated_at = $3 WHERE id = $4 AND "+owned, args...)
+	if err != nil {
+		logrus.Errorf("UpdateList: error while updating list:%s", err)
+		return fmt.Errorf("UpdateList: repository error:%w", err)
+	}
+	if err := checkRowsAffected(result); err != nil {
+		if errors.Is(err, ErrTodoNotFound) {
+			return ErrListNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteList deletes the todos of the list first, in the same transaction,
+// as the foreign key of todos.list_id does not cascade. That of the members
+// does.
+func (u *TodoPostgres) DeleteList(ctx context.Context, id string) ([]string, error) {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidListID
+	}
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	owned, args := listAccess(ctx, "id", models.ListOwner, []interface{}{listID})
+	rows, err := tx.QueryContext(ctx,
+		"DELETE FROM todos WHERE list_id IN (SELECT id FROM todo_lists WHERE id = $1 AND "+owned+") RETURNING id", args...)
+	if err != nil {
+		logrus.Errorf("DeleteList: error while deleting todos:%s", err)
+		return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+	}
+	todoIDs := []string{}
+	for rows.Next() {
+		var todoID int64
+		if err := rows.Scan(&todoID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+		}
+		todoIDs = append(todoIDs, strconv.FormatInt(todoID, 10))
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM todo_lists WHERE id = $1 AND "+owned, args...)
+	if err != nil {
+		logrus.Errorf("DeleteList: error while deleting list:%s", err)
+		return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+	}
+	if err := checkRowsAffected(result); err != nil {
+		if errors.Is(err, ErrTodoNotFound) {
+			return nil, ErrListNotFound
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("DeleteList: repository error:%w", err)
+	}
+	return todoIDs, nil
+}
+
+// MoveTodo needs ctx to be an editor of the todo and of the list, which must
+// belong to the owner of the todo. It checks the list up front to tell a
+// missing list from an archived one; the update checks it again, so a list
+// archived or deleted meanwhile leaves the todo as it is.
+func (u *TodoPostgres) MoveTodo(ctx context.Context, todoID, listID string, at time.Time) error {
+	id, err := strconv.Atoi(todoID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	list, err := nullID(listID, ErrInvalidListID)
+	if err != nil {
+		return err
+	}
+	if list != nil {
+		current, err := u.GetList(ctx, listID)
+		if err != nil {
+			return err
+		}
+		if current.Archived {
+			return ErrListArchived
+		}
+	}
+	owned, args := todoAccess(ctx, models.ListEditor, []interface{}{list, at, id})
+	target, args := listAccess(ctx, "id", models.ListEditor, args)
+	result, err := u.db.ExecContext(ctx, `
+		UPDATE todos SET list_id = $1, updated_at = $2
+		WHERE id = $3 AND `+owned+` AND ($1 IS NULL OR $1 IN (
+			SELECT id FROM todo_lists WHERE NOT archived AND owner_id = todos.owner_id AND `+target+`))`, args...)
+	if err != nil {
+		logrus.Errorf("MoveTodo: error while moving todo:%s", err)
+		return fmt.Errorf("MoveTodo: repository error:%w", err)
+	}
+	if err := checkRowsAffected(result); err != nil {
+		return u.unchangedTodo(ctx, id, err)
+	}
+	return nil
+}
+
+// ListRole reads the role from the members of the list. ctx reaching every
+// owner is an owner of every list.
+func (u *TodoPostgres) ListRole(ctx context.Context, id string) (models.ListRole, error) {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", ErrInvalidListID
+	}
+	query, args := "SELECT role FROM list_members WHERE list_id = $1 AND user_id = $2", []interface{}{listID, ownerScope(ctx)}
+	if args[1] == "" {
+		query, args = "SELECT '"+string(models.ListOwner)+"' FROM todo_lists WHERE id = $1", args[:1]
+	}
+	var role models.ListRole
+	if err := u.db.QueryRowContext(ctx, query, args...).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrListNotFound
+		}
+		logrus.Errorf("ListRole: error while scanning for role:%s", err)
+		return "", fmt.Errorf("ListRole: repository error:%w", err)
+	}
+	return role, nil
+}
+
+// ListMembers leaves the names and emails of the members empty; the users
+// may be stored by another backend.
+func (u *TodoPostgres) ListMembers(ctx context.Context, id string) ([]models.ListMember, error) {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidListID
+	}
+	if _, err := u.GetList(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := u.db.QueryContext(ctx, "SELECT user_id, role, created_at FROM list_members WHERE list_id = $1 ORDER BY user_id", listID)
+	if err != nil {
+		logrus.Errorf("ListMembers: can not executes a query:%s", err)
+		return nil, fmt.Errorf("ListMembers: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	members := []models.ListMember{}
+	for rows.Next() {
+		var member models.ListMember
+		if err := rows.Scan(&member.UserID, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListMembers: repository error:%w", err)
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// SetListMember keeps the user's role when the change would demote the last
+// owner of the list. It revokes the pending invitations of the user to the
+// list, which were issued for the membership it replaces.
+func (u *TodoPostgres) SetListMember(ctx context.Context, id, userID string, role models.ListRole, at time.Time) error {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrInvalidListID
+	}
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SetListMember: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setListMember(ctx, tx, listID, userID, role, at); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SetListMember: repository error:%w", err)
+	}
+	return nil
+}
+
+func setListMember(ctx context.Context, tx *sql.Tx, listID int64, userID string, role models.ListRole, at time.Time) error {
+	var exists int
+	if err := tx.QueryRowContext(ctx, "SELECT 1 FROM todo_lists WHERE id = $1", listID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrListNotFound
+		}
+		return fmt.Errorf("SetListMember: repository error:%w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO list_members (list_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (list_id, user_id) DO UPDATE SET role = excluded.role
+		WHERE excluded.role = '`+string(models.ListOwner)+`' OR `+otherOwner, listID, userID, role, at)
+	if err != nil {
+		logrus.Errorf("SetListMember: error while setting member:%s", err)
+		return fmt.Errorf("SetListMember: repository error:%w", err)
+	}
+	if err := checkRowsAffected(result); err != nil {
+		if errors.Is(err, ErrTodoNotFound) {
+			return ErrLastListOwner
+		}
+		return err
+	}
+	return revokeInvitations(ctx, tx, listID, userID, at)
+}
+
+// otherOwner is the SQL condition that the list $1 has an owner besides the
+// user $2.
+const otherOwner = `EXISTS (SELECT 1 FROM list_members m WHERE m.list_id = $1 AND m.user_id <> $2 AND m.role = '` + string(models.ListOwner) + `')`
+
+// RemoveListMember revokes the pending invitations of the user to the list
+// along with the membership.
+func (u *TodoPostgres) RemoveListMember(ctx context.Context, id, userID string, at time.Time) error {
+	listID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrInvalidListID
+	}
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("RemoveListMember: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM list_members WHERE list_id = $1 AND user_id = $2
+		AND (role <> '`+string(models.ListOwner)+`' OR `+otherOwner+`)`, listID, userID)
+	if err != nil {
+		logrus.Errorf("RemoveListMember: error while removing member:%s", err)
+		return fmt.Errorf("RemoveListMember: repository error:%w", err)
+	}
+	err = checkRowsAffected(result)
+	if err == nil {
+		if err := revokeInvitations(ctx, tx, listID, userID, at); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("RemoveListMember: repository error:%w", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrTodoNotFound) {
+		return err
+	}
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM list_members WHERE list_id = $1 AND user_id = $2", listID, userID).Scan(&exists)
+	switch {
+	case err == nil:
+		return ErrLastListOwner
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrListMemberNotFound
+	default:
+		return fmt.Errorf("RemoveListMember: repository error:%w", err)
+	}
+}
+
+func (u *TodoPostgres) CreateInvitation(ctx context.Context, id, listID, userID string, role models.ListRole, at time.Time) error {
+	list, err := strconv.ParseInt(listID, 10, 64)
+	if err != nil {
+		return ErrInvalidListID
+	}
+	if _, err := u.db.ExecContext(ctx,
+		"INSERT INTO list_invitations (id, list_id, user_id, role, created_at) VALUES ($1, $2, $3, $4, $5)",
+		id, list, userID, role, at); err != nil {
+		logrus.Errorf("CreateInvitation: error while inserting invitation:%s", err)
+		return fmt.Errorf("CreateInvitation: repository error:%w", err)
+	}
+	return nil
+}
+
+// AcceptInvitation marks the invitation accepted and sets the membership in
+// one transaction, so concurrent accepts of one token share the list once.
+func (u *TodoPostgres) AcceptInvitation(ctx context.Context, id, userID string, at time.Time) (string, error) {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("AcceptInvitation: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	var listID int64
+	var role models.ListRole
+	err = tx.QueryRowContext(ctx, `
+		UPDATE list_invitations SET accepted_at = $1
+		WHERE id = $2 AND user_id = $3 AND accepted_at IS NULL AND revoked_at IS NULL
+		RETURNING list_id, role`, at, id, userID).Scan(&listID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvitationNotFound
+		}
+		logrus.Errorf("AcceptInvitation: error while accepting invitation:%s", err)
+		return "", fmt.Errorf("AcceptInvitation: repository error:%w", err)
+	}
+	if err := setListMember(ctx, tx, listID, userID, role, at); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("AcceptInvitation: repository error:%w", err)
+	}
+	return strconv.FormatInt(listID, 10), nil
+}
+
+func (u *TodoPostgres) RevokeInvitations(ctx context.Context, listID, userID string, at time.Time) error {
+	list, err := strconv.ParseInt(listID, 10, 64)
+	if err != nil {
+		return ErrInvalidListID
+	}
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("RevokeInvitations: repository error:%w", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeInvitations(ctx, tx, list, userID, at); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RevokeInvitations: repository error:%w", err)
+	}
+	return nil
+}
+
+func revokeInvitations(ctx context.Context, tx *sql.Tx, listID int64, userID string, at time.Time) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE list_invitations SET revoked_at = $1
+		WHERE list_id = $2 AND user_id = $3 AND accepted_at IS NULL AND revoked_at IS NULL`,
+		at, listID, userID); err != nil {
+		logrus.Errorf("revokeInvitations: error while revoking invitations:%s", err)
+		return fmt.Errorf("revokeInvitations: repository error:%w", err)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"newFeatures/models"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,85 +20,232 @@ func NewTodoPostgres(db *sql.DB) *TodoPostgres {
 	return &TodoPostgres{db: db}
 }
 
-func (u TodoPostgres) GetTodoByID(id int) (*models.Todo, error) {
+func (u *TodoPostgres) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidTodoID
+	}
 	var todo models.Todo
-	result := u.db.QueryRow("SELECT id, title, done FROM todos WHERE id = $1", id)
-	if err := result.Scan(&todo.ID, &todo.Title, &todo.Done); err != nil {
+	var rowID int
+	owned, args := todoAccess(ctx, models.ListViewer, []interface{}{todoID})
+	result := u.db.QueryRowContext(ctx, "SELECT "+postgresTodoColumns+" FROM todos WHERE id = $1 AND "+owned, args...)
+	if err := result.Scan(postgresTodoFields(&todo, &rowID)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
 		logrus.Errorf("GetTodoByID: error while scanning for todo:%s", err)
 		return nil, fmt.Errorf("GetTodoByID: repository error:%w", err)
 	}
+	todo.ID = strconv.Itoa(rowID)
 	return &todo, nil
 }
 
-func (u *TodoPostgres) GetTodos(page, limit int64) ([]models.Todo, int, error) {
-	transaction, err := u.db.Begin()
+func (u *TodoPostgres) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, error) {
+	owned, args := todoAccess(ctx, models.ListViewer, nil)
+	args = append(args, limit, (page-1)*limit)
+	rows, err := u.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM todos WHERE %s ORDER BY id LIMIT $%d OFFSET $%d",
+		postgresTodoColumns, owned, len(args)-1, len(args)), args...)
 	if err != nil {
-		logrus.Errorf("GetTodos: can not starts transaction:%s", err)
-		return nil, 0, fmt.Errorf("GetTodos: can not starts transaction:%w", err)
-	}
-	var Todos []models.Todo
-	var query string
-	var pages int
-	var rows *sql.Rows
-	if page == 0 || limit == 0 {
-		query = "SELECT id, title, done FROM todos ORDER BY id"
-		rows, err = transaction.Query(query)
-		if err != nil {
-			logrus.Errorf("GetTodos: can not executes a query:%s", err)
-			return nil, 0, fmt.Errorf("GetTodos:repository error:%w", err)
-		}
-		pages = 1
-	} else {
-		query = "SELECT id, title, done FROM todos ORDER BY id LIMIT $1 OFFSET $2"
-		rows, err = transaction.Query(query, limit, (page-1)*limit)
-		if err != nil {
-			logrus.Errorf("GetTodos: can not executes a query:%s", err)
-			return nil, 0, fmt.Errorf("GetTodos:repository error:%w", err)
-		}
+		logrus.Errorf("GetTodos: can not executes a query:%s", err)
+		return nil, fmt.Errorf("GetTodos:repository error:%w", err)
 	}
+	defer rows.Close()
+
+	Todos := []models.Todo{}
 	for rows.Next() {
 		var Todo models.Todo
-		if err := rows.Scan(&Todo.ID, &Todo.Title, &Todo.Done); err != nil {
+		var rowID int
+		if err := rows.Scan(postgresTodoFields(&Todo, &rowID)...); err != nil {
 			logrus.Errorf("Error while scanning for todo:%s", err)
-			return nil, 0, fmt.Errorf("GetTodos:repository error:%w", err)
+			return nil, fmt.Errorf("GetTodos:repository error:%w", err)
 		}
+		Todo.ID = strconv.Itoa(rowID)
 		Todos = append(Todos, Todo)
 	}
-	if pages == 0 {
-		query = "SELECT CEILING(COUNT(id)/$1::float) FROM todos"
-		row := transaction.QueryRow(query, limit)
-		if err := row.Scan(&pages); err != nil {
-			logrus.Errorf("Error while scanning for pages:%s", err)
-		}
+	return Todos, rows.Err()
+}
+
+// postgresTodoColumns and postgresTodoList are shared with TodoSQLite, which
+// understands the same SQL. Unlike the other SQL backends they read the list
+// and the parent of a todo too, see postgresTodoFields.
+var postgresTodoColumns = sqlTodoColumns("done") + ", list_id, parent_id"
+
+// postgresTodoFields returns the scan destinations of postgresTodoColumns.
+func postgresTodoFields(todo *models.Todo, id interface{}) []interface{} {
+	return append(todoFields(todo, id), nullIDField{&todo.ListID}, nullIDField{&todo.ParentID})
+}
+
+// nullIDField scans a nullable integer id column, such as list_id, into a
+// string that is empty for NULL.
+type nullIDField struct {
+	dst *string
+}
+
+func (f nullIDField) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*f.dst = ""
+	case int64:
+		*f.dst = strconv.FormatInt(value, 10)
+	default:
+		return fmt.Errorf("unsupported id type %T", src)
+	}
+	return nil
+}
+
+// nullID converts an id such as the ListID of a todo into the value of its
+// nullable column, failing with invalid when it is not a number.
+func nullID(id string, invalid error) (interface{}, error) {
+	if id == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	return value, nil
+}
+
+var postgresTodoList = sqlTodoList{
+	placeholder: dollarPlaceholder,
+	done:        "done",
+	key:         intKeyValue,
+	id:          plainID,
+	contains:    lowerLike,
+	shared:      true,
+}
+
+func (u *TodoPostgres) ListTodos(ctx context.Context, query models.TodoQuery) ([]models.Todo, error) {
+	clauses, args, err := postgresTodoList.build(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := u.db.QueryContext(ctx, "SELECT "+postgresTodoColumns+" FROM todos"+clauses, args...)
+	if err != nil {
+		logrus.Errorf("ListTodos: can not executes a query:%s", err)
+		return nil, fmt.Errorf("ListTodos:repository error:%w", err)
+	}
+	return scanNumericTodos(rows, postgresTodoFields)
+}
+
+func (u *TodoPostgres) CountTodos(ctx context.Context) (int64, error) {
+	var count int64
+	owned, args := todoAccess(ctx, models.ListViewer, nil)
+	if err := u.db.QueryRowContext(ctx, "SELECT COUNT(id) FROM todos WHERE "+owned, args...).Scan(&count); err != nil {
+		logrus.Errorf("CountTodos: error while scanning for count:%s", err)
+		return 0, fmt.Errorf("CountTodos: repository error:%w", err)
 	}
-	return Todos, pages, transaction.Commit()
+	return count, nil
 }
 
-func (u *TodoPostgres) CreateTodo(todo *models.Todo) (int, error) {
+func (u *TodoPostgres) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	listID, err := nullID(todo.ListID, ErrInvalidListID)
+	if err != nil {
+		return "", err
+	}
+	parentID, err := nullID(todo.ParentID, ErrInvalidParent)
+	if err != nil {
+		return "", err
+	}
 	var id int
-	row := u.db.QueryRow("INSERT INTO todos (title, done) VALUES ($1, $2) RETURNING id", todo.Title, todo.Done)
+	row := u.db.QueryRowContext(ctx, `
+		INSERT INTO todos (owner_id, list_id, parent_id, title, description, done, due_at, priority, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		todo.OwnerID, listID, parentID, todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.CreatedAt, todo.UpdatedAt)
 	if err := row.Scan(&id); err != nil {
 		logrus.Errorf("CreateTodo: error while scanning for todo:%s", err)
-		return 0, fmt.Errorf("CreateTodo: error while scanning for todo:%w", err)
+		return "", fmt.Errorf("CreateTodo: error while scanning for todo:%w", err)
 	}
-	return id, nil
+	todo.ID = strconv.Itoa(id)
+	return todo.ID, nil
 }
 
-func (u *TodoPostgres) UpdateTodo(todo *models.Todo) error {
-	_, err := u.db.Exec("UPDATE todos SET title = $1, done = $2 WHERE id = $3", todo.Title, todo.Done, todo.ID)
+func (u *TodoPostgres) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	todoID, err := strconv.Atoi(todo.ID)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	owned, args := todoAccess(ctx, models.ListEditor, []interface{}{
+		todo.Title, todo.Description, todo.Done, todo.DueAt, todo.Priority, todo.UpdatedAt, todoID,
+	})
+	result, err := u.db.ExecContext(ctx, `
+		UPDATE todos SET title = $1, description = $2, done = $3, due_at = $4, priority = $5, updated_at = $6
+		WHERE id = $7 AND `+owned, args...)
 	if err != nil {
 		logrus.Errorf("UpdateTodo: error while updating todo:%s", err)
 		return fmt.Errorf("UpdateTodo: error while updating todo:%w", err)
 	}
+	if err := checkRowsAffected(result); err != nil {
+		return u.unchangedTodo(ctx, todoID, err)
+	}
 	return nil
 }
 
-func (u *TodoPostgres) DeleteTodoByID(id int) (int, error) {
-	var todoId int
-	row := u.db.QueryRow("DELETE FROM todos WHERE id=$1 RETURNING id", id)
-	if err := row.Scan(&todoId); err != nil {
+func (u *TodoPostgres) DeleteTodoByID(ctx context.Context, id string) error {
+	todoID, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrInvalidTodoID
+	}
+	var deletedID int
+	owned, args := todoAccess(ctx, models.ListEditor, []interface{}{todoID})
+	row := u.db.QueryRowContext(ctx, "DELETE FROM todos WHERE id = $1 AND "+owned+" RETURNING id", args...)
+	if err := row.Scan(&deletedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return u.unchangedTodo(ctx, todoID, ErrTodoNotFound)
+		}
 		logrus.Errorf("DeleteTodoByID: error while scanning for todoId:%s", err)
-		return 0, fmt.Errorf("DeleteTodoByID: error while scanning for todoId:%w", err)
+		return fmt.Errorf("DeleteTodoByID: error while scanning for todoId:%w", err)
+	}
+	return nil
+}
+
+// ChildTodos reads the subtasks of all parents in one query.
+func (u *TodoPostgres) ChildTodos(ctx context.Context, parentIDs []string) ([]models.Todo, error) {
+	if len(parentIDs) == 0 {
+		return []models.Todo{}, nil
 	}
-	return todoId, nil
+	args := make([]interface{}, len(parentIDs))
+	placeholders := make([]string, len(parentIDs))
+	for i, parentID := range parentIDs {
+		id, err := strconv.ParseInt(parentID, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidTodoID
+		}
+		args[i] = id
+		placeholders[i] = dollarPlaceholder(i + 1)
+	}
+	readable, args := todoAccess(ctx, models.ListViewer, args)
+	rows, err := u.db.QueryContext(ctx, "SELECT "+postgresTodoColumns+" FROM todos WHERE parent_id IN ("+
+		strings.Join(placeholders, ", ")+") AND "+readable+" ORDER BY id", args...)
+	if err != nil {
+		logrus.Errorf("ChildTodos: can not executes a query:%s", err)
+		return nil, fmt.Errorf("ChildTodos:repository error:%w", err)
+	}
+	return scanNumericTodos(rows, postgresTodoFields)
+}
+
+// unchangedTodo explains why a write matched no todo: err, or
+// ErrTodoForbidden when ctx may read the todo but not change it.
+func (u *TodoPostgres) unchangedTodo(ctx context.Context, todoID int, err error) error {
+	if !errors.Is(err, ErrTodoNotFound) {
+		return err
+	}
+	var exists int
+	readable, args := todoAccess(ctx, models.ListViewer, []interface{}{todoID})
+	if scanErr := u.db.QueryRowContext(ctx, "SELECT 1 FROM todos WHERE id = $1 AND "+readable, args...).Scan(&exists); scanErr == nil {
+		return ErrTodoForbidden
+	}
+	return err
+}
+
+// checkRowsAffected maps an UPDATE or DELETE that matched nothing to ErrTodoNotFound.
+func checkRowsAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTodoNotFound
+	}
+	return nil
 }
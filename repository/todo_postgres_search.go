@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"newFeatures/models"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Postgres search modes. A query is first matched with full-text search on
+// the search column; when that finds nothing, typos are tolerated by
+// matching the trigrams of title instead.
+const (
+	postgresSearchAll      = "all"
+	postgresSearchFullText = "fts"
+	postgresSearchTrigram  = "trgm"
+)
+
+// postgresSearch is the SQL of one search mode, in which $1 is the query.
+type postgresSearch struct {
+	match     string
+	score     string
+	highlight string
+}
+
+var postgresSearches = map[string]postgresSearch{
+	postgresSearchAll: {match: "TRUE", score: "0::float8", highlight: "NULL"},
+	postgresSearchFullText: {
+		match: "search @@ websearch_to_tsquery('english', $1)",
+		// The scores are compared with the cursor values, which only
+		// round-trip exactly as double precision.
+		score:     "ts_rank_cd(search, websearch_to_tsquery('english', $1))::float8",
+		highlight: "ts_headline('english', title, websearch_to_tsquery('english', $1), 'StartSel=<em>, StopSel=</em>, HighlightAll=true')",
+	},
+	postgresSearchTrigram: {
+		match:     "$1 <% title",
+		score:     "word_similarity($1, title)::float8",
+		highlight: "NULL",
+	},
+}
+
+// SearchTodos ranks the todos whose title matches query, see the
+// 0004_todo_search migration, and falls back to trigram similarity when no
+// title contains the words of query. Highlights mark the matched words of
+// full-text hits. Postgres has no term suggestions, so Suggestions stays
+// empty.
+func (u *TodoPostgres) SearchTodos(ctx context.Context, query string, page, limit int64) (*models.TodoSearchResult, error) {
+	mode, result, err := u.searchTotals(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	offset := (page - 1) * limit
+	if result.Hits, err = u.searchHits(ctx, mode, query, nil, limit, offset); err != nil {
+		return nil, err
+	}
+	result.HasMore = offset+int64(len(result.Hits)) < result.Total
+	return result, nil
+}
+
+// SearchTodosAfter pages by keyset on the score and id of the last hit,
+// which are what the cursors encode together with the search mode, so all
+// pages of a search keep the mode of its first page.
+func (u *TodoPostgres) SearchTodosAfter(ctx context.Context, query, after string, limit int64) (*models.TodoSearchResult, error) {
+	mode, result, err := u.searchTotals(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var key []interface{}
+	if after != "" {
+		if key, err = decodeCursor(after); err != nil {
+			return nil, err
+		}
+		if len(key) != 3 {
+			return nil, ErrInvalidCursor
+		}
+		cursorMode, _ := key[0].(string)
+		if _, ok := postgresSearches[cursorMode]; !ok || (cursorMode == postgresSearchAll) != (query == "") {
+			return nil, ErrInvalidCursor
+		}
+		if cursorMode != mode {
+			mode = cursorMode
+			if _, result, err = u.searchCount(ctx, mode, query); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if result.Hits, err = u.searchHits(ctx, mode, query, key, limit+1, 0); err != nil {
+		return nil, err
+	}
+	if int64(len(result.Hits)) > limit {
+		result.Hits = result.Hits[:limit]
+		result.HasMore = true
+	}
+	return result, nil
+}
+
+// searchTotals picks the search mode of query and counts its matches.
+func (u *TodoPostgres) searchTotals(ctx context.Context, query string) (string, *models.TodoSearchResult, error) {
+	if query == "" {
+		return u.searchCount(ctx, postgresSearchAll, query)
+	}
+	mode, result, err := u.searchCount(ctx, postgresSearchFullText, query)
+	if err != nil || result.Total > 0 {
+		return mode, result, err
+	}
+	return u.searchCount(ctx, postgresSearchTrigram, query)
+}
+
+// searchCount returns a result without hits that holds the number of
+// matches that ctx may read and the completed/open facet.
+func (u *TodoPostgres) searchCount(ctx context.Context, mode, query string) (string, *models.TodoSearchResult, error) {
+	search := postgresSearches[mode]
+	args := []interface{}{}
+	if mode != postgresSearchAll {
+		args = append(args, query)
+	}
+	owned, args := todoAccess(ctx, models.ListViewer, args)
+	result := &models.TodoSearchResult{Suggestions: []string{}}
+	row := u.db.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(*) FILTER (WHERE done) FROM todos WHERE "+search.match+" AND "+owned, args...)
+	if err := row.Scan(&result.Total, &result.Completed); err != nil {
+		logrus.Errorf("SearchTodos: error while counting todos:%s", err)
+		return "", nil, fmt.Errorf("SearchTodos: repository error:%w", err)
+	}
+	result.NotCompleted = result.Total - result.Completed
+	return mode, result, nil
+}
+
+// searchHits reads a page of the matches of mode that ctx may read,
+// best first, that follow the cursor values key when it is not nil.
+func (u *TodoPostgres) searchHits(ctx context.Context, mode, query string, key []interface{}, limit, offset int64) ([]models.TodoSearchHit, error) {
+	search := postgresSearches[mode]
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return dollarPlaceholder(len(args))
+	}
+	if mode != postgresSearchAll {
+		arg(query)
+	}
+	owned, args := todoAccess(ctx, models.ListViewer, args)
+
+	keyset := "TRUE"
+	if key != nil {
+		score, ok := key[1].(float64)
+		id, isNumber := key[2].(float64)
+		if !ok || !isNumber {
+			return nil, ErrInvalidCursor
+		}
+		keyset = fmt.Sprintf("(score < %s OR (score = %s AND id > %s))", arg(score), arg(score), arg(int64(id)))
+	}
+	rows, err := u.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %[1]s, score, %[2]s FROM (SELECT %[1]s, %[3]s AS score FROM todos WHERE %[4]s AND %[5]s) matches"+
+			" WHERE %[6]s ORDER BY score DESC, id LIMIT %[7]s OFFSET %[8]s",
+		postgresTodoColumns, search.highlight, search.score, search.match, owned, keyset, arg(limit), arg(offset)), args...)
+	if err != nil {
+		logrus.Errorf("SearchTodos: can not executes a query:%s", err)
+		return nil, fmt.Errorf("SearchTodos: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	hits := []models.TodoSearchHit{}
+	for rows.Next() {
+		var hit models.TodoSearchHit
+		var rowID int64
+		var highlight *string
+		if err := rows.Scan(append(postgresTodoFields(&hit.Todo, &rowID), &hit.Score, &highlight)...); err != nil {
+			return nil, fmt.Errorf("SearchTodos: repository error:%w", err)
+		}
+		hit.Todo.ID = strconv.FormatInt(rowID, 10)
+		hit.Highlights = []string{}
+		if highlight != nil {
+			hit.Highlights = append(hit.Highlights, *highlight)
+		}
+		if hit.Cursor, err = encodeCursor([]interface{}{mode, hit.Score, rowID}); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// SuggestTodos returns distinct titles of the todos ctx may read with a word
+// starting with prefix, titles that start with it first. The trigram index
+// serves the ILIKE.
+func (u *TodoPostgres) SuggestTodos(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	escaped := escapeLike(prefix)
+	owned, args := todoAccess(ctx, models.ListViewer, []interface{}{escaped + "%", "% " + escaped + "%", limit})
+	rows, err := u.db.QueryContext(ctx,
+		`SELECT title FROM todos WHERE (title ILIKE $1 ESCAPE '\' OR title ILIKE $2 ESCAPE '\') AND `+owned+
+			` GROUP BY title ORDER BY title NOT ILIKE $1 ESCAPE '\', title LIMIT $3`, args...)
+	if err != nil {
+		logrus.Errorf("SuggestTodos: can not executes a query:%s", err)
+		return nil, fmt.Errorf("SuggestTodos: repository error:%w", err)
+	}
+	defer rows.Close()
+
+	titles := []string{}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, fmt.Errorf("SuggestTodos: repository error:%w", err)
+		}
+		titles = append(titles, title)
+	}
+	return titles, rows.Err()
+}
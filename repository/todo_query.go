@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"newFeatures/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlTodoList translates a TodoQuery into the clauses of a SELECT for the
+// SQL backends, which differ in placeholders, column names and in how they
+// match a substring.
+type sqlTodoList struct {
+	// placeholder returns the n-th bind parameter, counting from 1.
+	placeholder func(n int) string
+	// done is the column of the completed flag and doneValue converts the
+	// filter value for it.
+	done      string
+	doneValue func(done bool) interface{}
+	// key converts the id of a cursor or of a list into a bind value and id
+	// wraps its placeholder, e.g. into a type conversion.
+	key func(id string) (interface{}, error)
+	id  func(param string) string
+	// contains returns the condition that title contains the value, bound
+	// with arg.
+	contains func(arg func(interface{}) string, value string) string
+	// at binds a timestamp with arg, arg(t) when nil.
+	at func(arg func(interface{}) string, t time.Time) string
+	// shared reaches the todos of the lists shared with the owner of the
+	// context too, see sharedTodos.
+	shared bool
+}
+
+// build returns the WHERE, ORDER BY and LIMIT clauses of query and their
+// arguments, confined to the owner scope of ctx. conditions are ANDed with
+// the filters of query. The list filter needs the list_id column, which only
+// the backends with TodoLists have.
+func (l sqlTodoList) build(ctx context.Context, query models.TodoQuery, conditions ...string) (string, []interface{}, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return l.placeholder(len(args))
+	}
+
+	if owner := ownerScope(ctx); owner != "" && l.shared {
+		conditions = append(conditions, sharedTodos(arg(owner), models.ListViewer))
+	} else if owner != "" {
+		conditions = append(conditions, "owner_id = "+arg(owner))
+	}
+	if query.ListID != "" {
+		listID, err := l.key(query.ListID)
+		if err != nil {
+			return "", nil, ErrInvalidListID
+		}
+		conditions = append(conditions, "list_id = "+l.id(arg(listID)))
+	}
+	if query.Done != nil {
+		var done interface{} = *query.Done
+		if l.doneValue != nil {
+			done = l.doneValue(*query.Done)
+		}
+		conditions = append(conditions, l.done+" = "+arg(done))
+	}
+	if query.Title != "" {
+		conditions = append(conditions, l.contains(arg, query.Title))
+	}
+	at := func(t time.Time) string {
+		if l.at != nil {
+			return l.at(arg, t)
+		}
+		return arg(t)
+	}
+	ranges := []struct {
+		column string
+		bounds models.TimeRange
+	}{{"created_at", query.Created}, {"due_at", query.Due}}
+	for _, r := range ranges {
+		if r.bounds.From != nil {
+			conditions = append(conditions, r.column+" >= "+at(*r.bounds.From))
+		}
+		if r.bounds.To != nil {
+			conditions = append(conditions, r.column+" < "+at(*r.bounds.To))
+		}
+	}
+
+	op, direction := ">", ""
+	if query.Sort.Desc {
+		op, direction = "<", " DESC"
+	}
+	// column is the sort field that precedes the id, if any.
+	var column string
+	switch query.Sort.Field {
+	case models.SortByTitle:
+		column = "title"
+	case models.SortByCreatedAt:
+		column = "created_at"
+	}
+	if query.After != nil {
+		id, err := l.key(query.After.ID)
+		if err != nil {
+			return "", nil, err
+		}
+		// value binds the sort field of the cursor.
+		var value func() string
+		switch column {
+		case "title":
+			value = func() string { return arg(query.After.Title) }
+		case "created_at":
+			if query.After.CreatedAt == nil {
+				return "", nil, ErrInvalidCursor
+			}
+			value = func() string { return at(*query.After.CreatedAt) }
+		}
+		if value == nil {
+			conditions = append(conditions, "id "+op+" "+l.id(arg(id)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))",
+				column, op, value(), column, value(), op, l.id(arg(id))))
+		}
+	}
+
+	var clauses strings.Builder
+	if len(conditions) > 0 {
+		clauses.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+	if column != "" {
+		clauses.WriteString(" ORDER BY " + column + direction + ", id" + direction)
+	} else {
+		clauses.WriteString(" ORDER BY id" + direction)
+	}
+	clauses.WriteString(" LIMIT " + arg(query.Limit))
+	return clauses.String(), args, nil
+}
+
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func questionPlaceholder(int) string {
+	return "?"
+}
+
+func plainID(param string) string {
+	return param
+}
+
+func intKeyValue(id string) (interface{}, error) {
+	return intKey(id)
+}
+
+// escapeLike escapes the wildcards of LIKE with backslashes.
+var escapeLike = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace
+
+// lowerLike matches case-insensitively with LIKE on both lowered sides,
+// which Postgres, SQLite and CockroachDB all support.
+func lowerLike(arg func(interface{}) string, value string) string {
+	return "LOWER(title) LIKE LOWER(" + arg("%"+escapeLike(value)+"%") + `) ESCAPE '\'`
+}
+
+// sqlTodoColumns lists the columns a todo is read from, done being the name
+// of the column of the completed flag; todoFields holds the matching scan
+// destinations.
+func sqlTodoColumns(done string) string {
+	return "id, owner_id, title, description, " + done + ", due_at, priority, created_at, updated_at"
+}
+
+// todoFields returns the scan destinations of sqlTodoColumns, scanning the
+// id into id.
+func todoFields(todo *models.Todo, id interface{}) []interface{} {
+	return []interface{}{id, &todo.OwnerID, &todo.Title, &todo.Description, &todo.Done, &todo.DueAt, &todo.Priority, &todo.CreatedAt, &todo.UpdatedAt}
+}
+
+// matchesTodo reports whether todo passes the filters of query.
+func matchesTodo(query models.TodoQuery, todo models.Todo) bool {
+	if query.ListID != "" && todo.ListID != query.ListID {
+		return false
+	}
+	if query.Done != nil && todo.Done != *query.Done {
+		return false
+	}
+	if !inRange(query.Created, &todo.CreatedAt) || !inRange(query.Due, todo.DueAt) {
+		return false
+	}
+	return query.Title == "" || strings.Contains(strings.ToLower(todo.Title), strings.ToLower(query.Title))
+}
+
+func inRange(bounds models.TimeRange, t *time.Time) bool {
+	if bounds.From == nil && bounds.To == nil {
+		return true
+	}
+	return t != nil && (bounds.From == nil || !t.Before(*bounds.From)) && (bounds.To == nil || t.Before(*bounds.To))
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"newFeatures/migration"
+	"newFeatures/models"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestSQLite opens a migrated SQLite database that lives as long as t.
+func newTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "todo.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migration.New(SQLiteDB, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLTodoListBuild(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	all := WithAllOwners(context.Background())
+	byOwner := sqlTodoList{placeholder: dollarPlaceholder, done: "done", key: intKeyValue, id: plainID, contains: lowerLike}
+
+	tests := []struct {
+		name    string
+		list    sqlTodoList
+		ctx     context.Context
+		query   models.TodoQuery
+		clauses string
+		args    []interface{}
+		err     error
+	}{
+		{
+			name:    "first page",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Limit: 10},
+			clauses: " ORDER BY id LIMIT $1",
+			args:    []interface{}{int64(10)},
+		},
+		{
+			name:    "id ascending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{After: &models.TodoKey{ID: "5"}, Limit: 10},
+			clauses: " WHERE id > $1 ORDER BY id LIMIT $2",
+			args:    []interface{}{int64(5), int64(10)},
+		},
+		{
+			name:    "id descending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Sort: models.TodoSort{Field: models.SortByID, Desc: true}, After: &models.TodoKey{ID: "5"}, Limit: 10},
+			clauses: " WHERE id < $1 ORDER BY id DESC LIMIT $2",
+			args:    []interface{}{int64(5), int64(10)},
+		},
+		{
+			name:    "title ascending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Sort: models.TodoSort{Field: models.SortByTitle}, After: &models.TodoKey{ID: "5", Title: "b"}, Limit: 10},
+			clauses: " WHERE (title > $1 OR (title = $2 AND id > $3)) ORDER BY title, id LIMIT $4",
+			args:    []interface{}{"b", "b", int64(5), int64(10)},
+		},
+		{
+			name:    "title descending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Sort: models.TodoSort{Field: models.SortByTitle, Desc: true}, After: &models.TodoKey{ID: "5", Title: "b"}, Limit: 10},
+			clauses: " WHERE (title < $1 OR (title = $2 AND id < $3)) ORDER BY title DESC, id DESC LIMIT $4",
+			args:    []interface{}{"b", "b", int64(5), int64(10)},
+		},
+		{
+			name:    "created_at ascending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Sort: models.TodoSort{Field: models.SortByCreatedAt}, After: &models.TodoKey{ID: "5", CreatedAt: &created}, Limit: 10},
+			clauses: " WHERE (created_at > $1 OR (created_at = $2 AND id > $3)) ORDER BY created_at, id LIMIT $4",
+			args:    []interface{}{created, created, int64(5), int64(10)},
+		},
+		{
+			name:    "created_at descending",
+			list:    postgresTodoList,
+			ctx:     all,
+			query:   models.TodoQuery{Sort: models.TodoSort{Field: models.SortByCreatedAt, Desc: true}, After: &models.TodoKey{ID: "5", CreatedAt: &created}, Limit: 10},
+			clauses: " WHERE (created_at < $1 OR (created_at = $2 AND id < $3)) ORDER BY created_at DESC, id DESC LIMIT $4",
+			args:    []interface{}{created, created, int64(5), int64(10)},
+		},
+		{
+			name:  "created_at cursor without the time",
+			list:  postgresTodoList,
+			ctx:   all,
+			query: models.TodoQuery{Sort: models.TodoSort{Field: models.SortByCreatedAt}, After: &models.TodoKey{ID: "5"}, Limit: 10},
+			err:   ErrInvalidCursor,
+		},
+		{
+			name:  "malformed cursor id",
+			list:  postgresTodoList,
+			ctx:   all,
+			query: models.TodoQuery{After: &models.TodoKey{ID: "five"}, Limit: 10},
+			err:   ErrInvalidCursor,
+		},
+		{
+			name:    "owner scope",
+			list:    byOwner,
+			ctx:     WithOwner(context.Background(), "7"),
+			query:   models.TodoQuery{After: &models.TodoKey{ID: "5"}, Limit: 10},
+			clauses: " WHERE owner_id = $1 AND id > $2 ORDER BY id LIMIT $3",
+			args:    []interface{}{"7", int64(5), int64(10)},
+		},
+		{
+			name:    "shared lists",
+			list:    postgresTodoList,
+			ctx:     WithOwner(context.Background(), "7"),
+			query:   models.TodoQuery{Limit: 10},
+			clauses: " WHERE " + sharedTodos("$1", models.ListViewer) + " ORDER BY id LIMIT $2",
+			args:    []interface{}{"7", int64(10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clauses, args, err := tt.list.build(tt.ctx, tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("build() error = %v, want %v", err, tt.err)
+			}
+			if clauses != tt.clauses {
+				t.Errorf("build() clauses = %q, want %q", clauses, tt.clauses)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("build() args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+// pageThrough lists every todo of query page by page, starting each page
+// after the last todo of the one before.
+func pageThrough(t *testing.T, store TodoStore, ctx context.Context, query models.TodoQuery, max int) []string {
+	t.Helper()
+	ids := []string{}
+	for pages := 0; ; pages++ {
+		if pages > max {
+			t.Fatalf("ListTodos() did not stop after %d pages: %v", max, ids)
+		}
+		page, err := store.ListTodos(ctx, query)
+		if err != nil {
+			t.Fatalf("ListTodos() error = %v", err)
+		}
+		for _, todo := range page {
+			ids = append(ids, todo.ID)
+		}
+		if int64(len(page)) < query.Limit {
+			return ids
+		}
+		last := page[len(page)-1]
+		createdAt := last.CreatedAt
+		query.After = &models.TodoKey{ID: last.ID, Title: last.Title, CreatedAt: &createdAt}
+	}
+}
+
+// sortedIDs returns the ids of todos in the order of order, ties broken by
+// the numeric id in the same direction.
+func sortedIDs(todos []models.Todo, order models.TodoSort) []string {
+	sorted := append([]models.Todo(nil), todos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if order.Desc {
+			a, b = b, a
+		}
+		switch {
+		case order.Field == models.SortByTitle && a.Title != b.Title:
+			return a.Title < b.Title
+		case order.Field == models.SortByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		idA, _ := strconv.Atoi(a.ID)
+		idB, _ := strconv.Atoi(b.ID)
+		return idA < idB
+	})
+	ids := make([]string, len(sorted))
+	for i, todo := range sorted {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func TestListTodosKeysetPaging(t *testing.T) {
+	stores := []struct {
+		name string
+		new  func(t *testing.T) TodoStore
+	}{
+		{"memory", func(*testing.T) TodoStore { return NewTodoMemory() }},
+		{"sqlite", func(t *testing.T) TodoStore { return NewTodoSQLite(newTestSQLite(t)) }},
+	}
+	sorts := []models.TodoSort{
+		{Field: models.SortByID},
+		{Field: models.SortByID, Desc: true},
+		{Field: models.SortByTitle},
+		{Field: models.SortByTitle, Desc: true},
+		{Field: models.SortByCreatedAt},
+		{Field: models.SortByCreatedAt, Desc: true},
+	}
+	// Repeated titles and creation times make the id break the ties.
+	titles := []string{"b", "a", "b", "c", "a", "b", "c", "a"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			todos := store.new(t)
+			ctx := WithOwner(context.Background(), "1")
+			created := []models.Todo{}
+			for i, title := range titles {
+				at := start.Add(time.Duration(i%3) * time.Hour)
+				todo := models.Todo{OwnerID: "1", Title: title, CreatedAt: at, UpdatedAt: at}
+				if _, err := todos.CreateTodo(ctx, &todo); err != nil {
+					t.Fatal(err)
+				}
+				created = append(created, todo)
+			}
+			other := models.Todo{OwnerID: "2", Title: "a", CreatedAt: start, UpdatedAt: start}
+			if _, err := todos.CreateTodo(ctx, &other); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, order := range sorts {
+				for _, limit := range []int64{1, 2, 3, 100} {
+					name := string(order.Field)
+					if order.Desc {
+						name = "-" + name
+					}
+					t.Run(name+"/"+strconv.FormatInt(limit, 10), func(t *testing.T) {
+						got := pageThrough(t, todos, ctx, models.TodoQuery{Sort: order, Limit: limit}, len(titles)+1)
+						if want := sortedIDs(created, order); !reflect.DeepEqual(got, want) {
+							t.Errorf("pages = %v, want %v", got, want)
+						}
+					})
+				}
+			}
+		})
+	}
+}
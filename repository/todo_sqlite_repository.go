@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+// TodoSQLite stores todos in an embedded SQLite file. SQLite accepts the
+// $N placeholders and RETURNING clauses used by TodoPostgres, so every
+// query of TodoPostgres is reused as-is; they number their placeholders in
+// the order they appear, as SQLite requires. Full-text search, which is
+// Postgres SQL, is not offered, see NewRepository.
+type TodoSQLite struct {
+	*TodoPostgres
+}
+
+func NewTodoSQLite(db *sql.DB) *TodoSQLite {
+	return &TodoSQLite{TodoPostgres: NewTodoPostgres(db)}
+}
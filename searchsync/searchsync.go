@@ -0,0 +1,307 @@
+// Package searchsync keeps the Elasticsearch index in step with the todos
+// table of Postgres, which stays the system of record.
+//
+// A trigger on todos appends the id of every inserted, updated or deleted
+// todo to the todo_changes log and notifies the todo_changes channel. The
+// syncer installs the trigger when it first runs and then resyncs the whole
+// table, as the changes made before were not logged. The
+// syncer reads the current row of each logged todo, upserts or deletes its
+// document in bulk and then removes the applied changes from the log, which
+// is its checkpoint: after a crash the changes that were not acknowledged
+// are applied again, which is harmless because documents are always
+// rewritten from the current row.
+package searchsync
+
+import (
+	"context"
+	"fmt"
+	"newFeatures/repository"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type Syncer struct {
+	changes *repository.TodoChanges
+	index   *repository.Repository
+	batch   int64
+}
+
+// NewSyncer syncs the changes logged in Postgres into index, which must be
+// an Elasticsearch repository, batch todos at a time.
+func NewSyncer(changes *repository.TodoChanges, index *repository.Repository, batch int64) *Syncer {
+	return &Syncer{changes: changes, index: index, batch: batch}
+}
+
+// ResyncStats reports what a full resync did.
+type ResyncStats struct {
+	Indexed int64
+	Pruned  int64
+	Failed  int64
+}
+
+// Run syncs the pending changes and then waits for more until ctx is done.
+// Besides the notifications it syncs every poll interval, which catches
+// notifications lost while the listener was reconnecting. Failed changes
+// stay in the log and are retried on the next round. If Run installs the
+// change log trigger, it resyncs the table first.
+func (s *Syncer) Run(ctx context.Context, listener *pq.Listener, poll time.Duration) {
+	captured, resync := false, false
+	for {
+		if !captured {
+			installed, err := s.changes.Capture(ctx)
+			if err != nil && ctx.Err() == nil {
+				logrus.Errorf("Search sync: installing the change log trigger: %s", err)
+			}
+			captured, resync = err == nil, installed
+		}
+		if resync {
+			stats, err := s.Resync(ctx, false)
+			if err != nil && ctx.Err() == nil {
+				logrus.Errorf("Search resync: %s", err)
+			} else if stats.Failed > 0 {
+				logrus.Errorf("Search resync: %d todos failed, run searchsync resync to retry", stats.Failed)
+			}
+			resync = err != nil
+		}
+		// Until the trigger is installed there is nothing to sync.
+		if captured {
+			if n, err := s.Sync(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logrus.Errorf("Search sync: %s", err)
+			} else if n > 0 {
+				logrus.Debugf("Search sync: applied %d changes", n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+			// One round covers everything notified so far.
+			for len(listener.Notify) > 0 {
+				<-listener.Notify
+			}
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Sync applies the pending changes batch by batch until the log is empty and
+// returns how many it applied.
+func (s *Syncer) Sync(ctx context.Context) (int, error) {
+	// The index mirrors the todos of every owner.
+	ctx = repository.WithAllOwners(ctx)
+	applied := 0
+	for {
+		changes, err := s.changes.Pending(ctx, s.batch)
+		if err != nil {
+			return applied, err
+		}
+		if len(changes) == 0 {
+			return applied, nil
+		}
+		n, err := s.apply(ctx, changes)
+		applied += n
+		if err != nil {
+			return applied, err
+		}
+		if n < len(changes) {
+			// The failed changes would be read again right away.
+			return applied, fmt.Errorf("%d changes failed and are kept for the next round", len(changes)-n)
+		}
+	}
+}
+
+// apply writes the current state of the changed todos to the index and
+// acknowledges the changes of the todos that were written.
+func (s *Syncer) apply(ctx context.Context, changes []repository.TodoChange) (int, error) {
+	ids := make([]string, 0, len(changes))
+	seen := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		if !seen[change.TodoID] {
+			seen[change.TodoID] = true
+			ids = append(ids, change.TodoID)
+		}
+	}
+
+	todos, err := s.changes.TodosByID(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	existing := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		existing[todo.ID] = true
+	}
+	deleted := make([]string, 0, len(ids)-len(todos))
+	for _, id := range ids {
+		if !existing[id] {
+			deleted = append(deleted, id)
+		}
+	}
+
+	failed := make(map[string]bool)
+	if len(todos) > 0 {
+		results, err := s.index.BulkIndexTodos(ctx, todos, "")
+		if err != nil {
+			return 0, err
+		}
+		for i, result := range results {
+			if result.Error != "" {
+				logrus.Errorf("Search sync: indexing todo %s: %s", todos[i].ID, result.Error)
+				failed[todos[i].ID] = true
+			}
+		}
+	}
+	if len(deleted) > 0 {
+		results, err := s.index.BulkDeleteTodos(ctx, deleted, "")
+		if err != nil {
+			return 0, err
+		}
+		for i, result := range results {
+			if result.Error != "" {
+				logrus.Errorf("Search sync: deleting todo %s: %s", deleted[i], result.Error)
+				failed[deleted[i]] = true
+			}
+		}
+	}
+
+	seqs := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		if !failed[change.TodoID] {
+			seqs = append(seqs, change.Seq)
+		}
+	}
+	if err := s.changes.Ack(ctx, seqs); err != nil {
+		return 0, err
+	}
+	return len(seqs), nil
+}
+
+// Resync copies every todo of Postgres into the index, installing the
+// change log trigger first if it is not yet. The changes logged before the
+// copy started are covered by it and acknowledged; those logged while it
+// runs stay in the log for Sync. With prune, documents without a todo in
+// Postgres are deleted afterwards, including any created through the
+// Elasticsearch API directly.
+func (s *Syncer) Resync(ctx context.Context, prune bool) (ResyncStats, error) {
+	ctx = repository.WithAllOwners(ctx)
+	var stats ResyncStats
+	if _, err := s.changes.Capture(ctx); err != nil {
+		return stats, err
+	}
+	// Only the changes committed by now are covered by the copy. One with a
+	// lower Seq may still commit after the copy read its todo, so the log is
+	// not acknowledged up to a Seq.
+	covered, err := s.changes.Logged(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	var after int64
+	for {
+		todos, err := s.changes.TodosAfter(ctx, after, s.batch)
+		if err != nil {
+			return stats, err
+		}
+		if len(todos) == 0 {
+			break
+		}
+		results, err := s.index.BulkIndexTodos(ctx, todos, "")
+		if err != nil {
+			return stats, err
+		}
+		for i, result := range results {
+			if result.Error != "" {
+				logrus.Errorf("Search resync: indexing todo %s: %s", todos[i].ID, result.Error)
+				stats.Failed++
+				continue
+			}
+			stats.Indexed++
+		}
+		if after, err = strconv.ParseInt(todos[len(todos)-1].ID, 10, 64); err != nil {
+			return stats, err
+		}
+	}
+
+	// Failed todos keep their changes in the log, if they have any.
+	if stats.Failed == 0 {
+		for len(covered) > 0 {
+			n := int64(len(covered))
+			if n > s.batch {
+				n = s.batch
+			}
+			if err := s.changes.Ack(ctx, covered[:n]); err != nil {
+				return stats, err
+			}
+			covered = covered[n:]
+		}
+	}
+
+	if prune {
+		pruned, failed, err := s.prune(ctx)
+		stats.Pruned = pruned
+		stats.Failed += failed
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// prune deletes the documents whose todo no longer exists in Postgres.
+func (s *Syncer) prune(ctx context.Context) (int64, int64, error) {
+	var pruned, failed int64
+	var cursor string
+	for {
+		page, err := s.index.SearchTodosAfter(ctx, "", cursor, s.batch)
+		if err != nil {
+			return pruned, failed, err
+		}
+		if len(page.Hits) == 0 {
+			return pruned, failed, nil
+		}
+
+		ids := make([]string, len(page.Hits))
+		for i, hit := range page.Hits {
+			ids[i] = hit.Todo.ID
+		}
+		todos, err := s.changes.TodosByID(ctx, ids)
+		if err != nil {
+			return pruned, failed, err
+		}
+		existing := make(map[string]bool, len(todos))
+		for _, todo := range todos {
+			existing[todo.ID] = true
+		}
+		stale := make([]string, 0, len(ids)-len(todos))
+		for _, id := range ids {
+			if !existing[id] {
+				stale = append(stale, id)
+			}
+		}
+		if len(stale) > 0 {
+			results, err := s.index.BulkDeleteTodos(ctx, stale, "")
+			if err != nil {
+				return pruned, failed, err
+			}
+			for i, result := range results {
+				if result.Error != "" {
+					logrus.Errorf("Search resync: deleting todo %s: %s", stale[i], result.Error)
+					failed++
+					continue
+				}
+				pruned++
+			}
+		}
+
+		if !page.HasMore {
+			return pruned, failed, nil
+		}
+		cursor = page.Hits[len(page.Hits)-1].Cursor
+	}
+}
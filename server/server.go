@@ -24,9 +24,10 @@ func (s *Server) Run(port string, router *gin.Engine) error {
 		Handler:      router,
 		ErrorLog:     log.New(os.Stderr, "ERROR: ", log.LstdFlags),
 	}
+	s.httpServer = server
 
 	err := server.ListenAndServe()
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("error running server: %s", err)
 	}
 	return nil
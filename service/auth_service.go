@@ -75,12 +75,28 @@ func (a *AuthorizationService) User(ctx context.Context, userID int) (*models.Re
 	return user, nil
 }
 
-func (a *AuthorizationService) Users(ctx context.Context, page, limit int64) ([]models.ResponseUser, error) {
-	users, err := a.repository.AuthorizationApp.Users(ctx, page, limit)
+// Users returns the page of users that follows cursor, by id.
+func (a *AuthorizationService) Users(ctx context.Context, cursor string, limit int64) (*models.Page[models.ResponseUser], error) {
+	if limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	var afterID int
+	if cursor != "" {
+		if err := decodeCursor("users", cursor, &afterID); err != nil {
+			return nil, err
+		}
+	}
+	users, err := a.repository.AuthorizationApp.Users(ctx, afterID, limit+1)
 	if err != nil {
 		return nil, err
 	}
-	return users, nil
+
+	page := &models.Page[models.ResponseUser]{Items: users}
+	if int64(len(users)) > limit {
+		page.Items = users[:limit]
+		page.NextCursor = encodeCursor("users", users[limit-1].Id)
+	}
+	return page, nil
 }
 
 func (a *AuthorizationService) UpdateUser(ctx context.Context, inputUser *models.ResponseUser) error {
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"newFeatures/repository"
+	"os"
+	"strings"
+)
+
+// cursor is what a cursor token carries: the list it was issued for and the
+// position in it the next page starts after.
+type cursor struct {
+	Scope string          `json:"s"`
+	After json.RawMessage `json:"a"`
+}
+
+// cursorKey signs cursor tokens. It is CURSOR_KEY, or TOKEN_KEY when unset,
+// so a rotated key invalidates the cursors handed out before.
+func cursorKey() []byte {
+	if key := os.Getenv("CURSOR_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv("TOKEN_KEY"))
+}
+
+// encodeCursor returns an opaque token for the page after the position after
+// of the list scope: the base64 JSON payload and its HMAC-SHA256, separated
+// by a dot. Clients cannot forge or edit it, nor use it on another list.
+func encodeCursor(scope string, after interface{}) string {
+	position, _ := json.Marshal(after)
+	payload, _ := json.Marshal(cursor{Scope: scope, After: position})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(cursorMAC(encoded))
+}
+
+// decodeCursor verifies a token of encodeCursor and stores its position in
+// after.
+func decodeCursor(scope, token string, after interface{}) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return repository.ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, cursorMAC(encoded)) {
+		return repository.ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return repository.ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.Scope != scope {
+		return repository.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(c.After, after); err != nil {
+		return repository.ErrInvalidCursor
+	}
+	return nil
+}
+
+func cursorMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, cursorKey())
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
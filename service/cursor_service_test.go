@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flip changes the i-th character of token to another base64 character.
+func flip(token string, i int) string {
+	c := byte('A')
+	if token[i] == c {
+		c = 'B'
+	}
+	return token[:i] + string(c) + token[i+1:]
+}
+
+func TestCursor(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	position := models.TodoKey{ID: "42", Title: "milk", CreatedAt: &created}
+
+	tests := []struct {
+		name  string
+		scope string
+		// edit changes the token before it is decoded.
+		edit func(token string) string
+		// key is the CURSOR_KEY when the token is decoded.
+		key string
+		err error
+	}{
+		{name: "round trip", scope: "todos:memory"},
+		{name: "other list", scope: "todos:sqlite", err: repository.ErrInvalidCursor},
+		{
+			name:  "edited payload",
+			scope: "todos:memory",
+			edit: func(token string) string {
+				return flip(token, 4)
+			},
+			err: repository.ErrInvalidCursor,
+		},
+		{
+			name:  "edited signature",
+			scope: "todos:memory",
+			edit: func(token string) string {
+				return flip(token, len(token)-2)
+			},
+			err: repository.ErrInvalidCursor,
+		},
+		{
+			name:  "no signature",
+			scope: "todos:memory",
+			edit: func(token string) string {
+				payload, _, _ := strings.Cut(token, ".")
+				return payload
+			},
+			err: repository.ErrInvalidCursor,
+		},
+		{name: "garbage", scope: "todos:memory", edit: func(string) string { return "not a cursor" }, err: repository.ErrInvalidCursor},
+		{name: "rotated key", scope: "todos:memory", key: "rotated", err: repository.ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CURSOR_KEY", "cursor")
+			token := encodeCursor("todos:memory", position)
+			if tt.edit != nil {
+				token = tt.edit(token)
+			}
+			if tt.key != "" {
+				t.Setenv("CURSOR_KEY", tt.key)
+			}
+
+			var got models.TodoKey
+			err := decodeCursor(tt.scope, token, &got)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("decodeCursor() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (got.ID != position.ID || got.Title != position.Title || !got.CreatedAt.Equal(created)) {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, position)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"newFeatures/mail"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// InvitationTTL is how long an invitation to a list can be accepted.
+const InvitationTTL = time.Hour * 24 * 7
+
+var (
+	ErrListsNotSupported = errors.New("lists are not supported by the configured database")
+	ErrEmptyListName     = errors.New("list name is empty")
+	ErrLongListName      = fmt.Errorf("list name is longer than %d bytes", maxTitleLength)
+	ErrListForbidden     = errors.New("not enough rights on the list")
+	ErrInvalidListRole   = fmt.Errorf("list role must be %s, %s or %s", models.ListViewer, models.ListEditor, models.ListOwner)
+	ErrInvalidInvitation = errors.New("invitation is invalid, expired, used, revoked or for another user")
+)
+
+func validateList(list *models.TodoList) error {
+	switch {
+	case list.Name == "":
+		return ErrEmptyListName
+	case len(list.Name) > maxTitleLength:
+		return ErrLongListName
+	}
+	return nil
+}
+
+// checkTodoList checks that a todo about to be created may go into the list
+// it names: the list is not archived and the owner of ctx is an editor of
+// it.
+func (s *TodoService) checkTodoList(ctx context.Context, todo *models.Todo) error {
+	if todo.ListID == "" {
+		return nil
+	}
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	list, err := s.repository.TodoLists.GetList(ctx, todo.ListID)
+	if err != nil {
+		return err
+	}
+	if list.Archived {
+		return repository.ErrListArchived
+	}
+	return s.requireListRole(ctx, todo.ListID, models.ListEditor)
+}
+
+// requireListRole fails with ErrListForbidden unless the owner of ctx has
+// role on the list.
+func (s *TodoService) requireListRole(ctx context.Context, listID string, role models.ListRole) error {
+	current, err := s.repository.TodoLists.ListRole(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if !current.Includes(role) {
+		return ErrListForbidden
+	}
+	return nil
+}
+
+// GetLists returns the page of archived or active lists that follows cursor,
+// the first page for an empty cursor.
+func (s *TodoService) GetLists(ctx context.Context, archived bool, cursor string, limit int64) (*models.Page[models.TodoList], error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	if limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	scope := "lists:" + s.backend
+	if archived {
+		scope += ":archived"
+	}
+	query := models.TodoListQuery{Archived: archived, Limit: limit + 1}
+	if cursor != "" {
+		if err := decodeCursor(scope, cursor, &query.AfterID); err != nil {
+			return nil, err
+		}
+	}
+	lists, err := s.repository.TodoLists.Lists(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lists: %w", err)
+	}
+
+	page := &models.Page[models.TodoList]{Items: lists}
+	if int64(len(lists)) > limit {
+		page.Items = lists[:limit]
+		page.NextCursor = encodeCursor(scope, lists[limit-1].ID)
+	}
+	return page, nil
+}
+
+func (s *TodoService) GetList(ctx context.Context, id string) (*models.TodoList, error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	return s.repository.TodoLists.GetList(ctx, id)
+}
+
+// CreateList stores a list of the owner of ctx. Its counts are ignored.
+func (s *TodoService) CreateList(ctx context.Context, list *models.TodoList) (string, error) {
+	if s.repository.TodoLists == nil {
+		return "", ErrListsNotSupported
+	}
+	if err := validateList(list); err != nil {
+		return "", err
+	}
+	list.OwnerID = repository.OwnerFromContext(ctx)
+	list.CreatedAt = now()
+	list.UpdatedAt = list.CreatedAt
+	id, err := s.repository.TodoLists.CreateList(ctx, list)
+	if err != nil {
+		return "", fmt.Errorf("failed to create list: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateList renames, archives or restores a list of which the owner of ctx
+// is an owner. Archiving keeps the todos in the list.
+func (s *TodoService) UpdateList(ctx context.Context, list *models.TodoList) error {
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	if err := validateList(list); err != nil {
+		return err
+	}
+	if err := s.requireListRole(ctx, list.ID, models.ListOwner); err != nil {
+		return err
+	}
+	list.UpdatedAt = now()
+	return s.repository.TodoLists.UpdateList(ctx, list)
+}
+
+// DeleteList deletes a list of which the owner of ctx is an owner with its
+// todos and returns the ids of the todos.
+func (s *TodoService) DeleteList(ctx context.Context, id string) ([]string, error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	if err := s.requireListRole(ctx, id, models.ListOwner); err != nil {
+		return nil, err
+	}
+	return s.repository.TodoLists.DeleteList(ctx, id)
+}
+
+// MoveTodo puts a todo with its subtasks into a list, or takes them out of
+// their list when listID is empty, and returns the ids of the subtasks. The
+// owner of ctx must be an editor of both lists, and the list must belong to
+// the owner of the todo, so an editor cannot take todos into a list of
+// their own. Subtasks only move with their parent.
+func (s *TodoService) MoveTodo(ctx context.Context, todoID, listID string) ([]string, error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	var target *models.TodoList
+	if listID != "" {
+		if err := s.requireListRole(ctx, listID, models.ListEditor); err != nil {
+			return nil, err
+		}
+		list, err := s.repository.TodoLists.GetList(ctx, listID)
+		if err != nil {
+			return nil, err
+		}
+		target = list
+	}
+	todo, err := s.repository.TodoStore.GetTodoByID(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if todo.ParentID != "" {
+		return nil, ErrSubtaskMove
+	}
+	if target != nil && target.OwnerID != todo.OwnerID {
+		return nil, ErrListForbidden
+	}
+	subtasks, err := s.descendants(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	at := now()
+	if err := s.repository.TodoLists.MoveTodo(ctx, todoID, listID, at); err != nil {
+		return nil, err
+	}
+	moved := []string{}
+	for _, subtask := range subtasks {
+		if err := s.repository.TodoLists.MoveTodo(ctx, subtask.ID, listID, at); err != nil {
+			return moved, err
+		}
+		moved = append(moved, subtask.ID)
+	}
+	return moved, nil
+}
+
+// ListMembers returns who has access to a list, with the names and emails
+// of the users that still exist.
+func (s *TodoService) ListMembers(ctx context.Context, listID string) ([]models.ListMember, error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	members, err := s.repository.TodoLists.ListMembers(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	if s.users == nil {
+		return members, nil
+	}
+	for i, member := range members {
+		userID, err := strconv.Atoi(member.UserID)
+		if err != nil {
+			continue
+		}
+		if user, err := s.users.UserById(ctx, userID); err == nil {
+			members[i].Name = user.Name
+			members[i].Email = user.Email
+		}
+	}
+	return members, nil
+}
+
+// invitationClaims is the payload of an invitation token. The subject is
+// the id of the invited user.
+type invitationClaims struct {
+	jwt.RegisteredClaims
+	Backend string          `json:"backend"`
+	ListID  string          `json:"list_id"`
+	Role    models.ListRole `json:"role"`
+}
+
+// sendInvitation mails an invitation; the tests read the token from it.
+var sendInvitation = mail.SendInvitation
+
+// invitationKey signs the invitation tokens. It is derived from TOKEN_KEY
+// but differs from the key of the access tokens, so that neither token can
+// pass for the other.
+func invitationKey() []byte {
+	return []byte("invitation:" + os.Getenv("TOKEN_KEY"))
+}
+
+// InviteToList mails the registered user with email a token that shares
+// the list with them as role once they accept it. The token names an
+// invitation stored with the list, which RevokeInvitations and any later
+// change to the user's membership revoke. The owner of ctx must be an
+// owner of the list.
+func (s *TodoService) InviteToList(ctx context.Context, listID, email string, role models.ListRole) error {
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	if !role.Valid() {
+		return ErrInvalidListRole
+	}
+	if err := s.requireListRole(ctx, listID, models.ListOwner); err != nil {
+		return err
+	}
+	list, err := s.repository.TodoLists.GetList(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if s.users == nil {
+		return ErrorEmailDoesNotExist
+	}
+	user, err := s.users.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrorEmailDoesNotExist
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	id, userID, at := uuid.New().String(), strconv.Itoa(user.Id), now()
+	if err := s.repository.TodoLists.CreateInvitation(ctx, id, list.ID, userID, role, at); err != nil {
+		return err
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &invitationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(at.Add(InvitationTTL)),
+			IssuedAt:  jwt.NewNumericDate(at),
+		},
+		Backend: s.backend,
+		ListID:  list.ID,
+		Role:    role,
+	}).SignedString(invitationKey())
+	if err != nil {
+		return err
+	}
+	go sendInvitation(&models.Invitation{
+		Email:    user.Email,
+		ListName: list.Name,
+		Role:     role,
+		Token:    token,
+	})
+	return nil
+}
+
+// AcceptInvitation shares the list of an invitation with the owner of ctx,
+// who must be the invited user, and returns the list. The role of the
+// invitation replaces the role the user had on the list. An invitation is
+// accepted once; revoked ones fail like expired ones.
+func (s *TodoService) AcceptInvitation(ctx context.Context, token string) (*models.TodoList, error) {
+	if s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	var claims invitationClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidInvitation
+		}
+		return invitationKey(), nil
+	})
+	if err != nil || !parsed.Valid || claims.Backend != s.backend || claims.ID == "" ||
+		claims.Subject != repository.OwnerFromContext(ctx) {
+		return nil, ErrInvalidInvitation
+	}
+	listID, err := s.repository.TodoLists.AcceptInvitation(ctx, claims.ID, claims.Subject, now())
+	if err != nil {
+		if errors.Is(err, repository.ErrInvitationNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	return s.repository.TodoLists.GetList(ctx, listID)
+}
+
+// RevokeInvitations revokes the invitations to a list that a user has not
+// accepted yet. The owner of ctx must be an owner of the list.
+func (s *TodoService) RevokeInvitations(ctx context.Context, listID, userID string) error {
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	if err := s.requireListRole(ctx, listID, models.ListOwner); err != nil {
+		return err
+	}
+	return s.repository.TodoLists.RevokeInvitations(ctx, listID, userID, now())
+}
+
+// SetListMemberRole changes the role of a member of a list. The owner of
+// ctx must be an owner of the list.
+func (s *TodoService) SetListMemberRole(ctx context.Context, listID, userID string, role models.ListRole) error {
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	if !role.Valid() {
+		return ErrInvalidListRole
+	}
+	if err := s.requireListRole(ctx, listID, models.ListOwner); err != nil {
+		return err
+	}
+	members, err := s.repository.TodoLists.ListMembers(ctx, listID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return s.repository.TodoLists.SetListMember(ctx, listID, userID, role, now())
+		}
+	}
+	return repository.ErrListMemberNotFound
+}
+
+// RemoveListMember stops sharing a list with a user. Owners of the list
+// remove any member, the other members only themselves.
+func (s *TodoService) RemoveListMember(ctx context.Context, listID, userID string) error {
+	if s.repository.TodoLists == nil {
+		return ErrListsNotSupported
+	}
+	if userID != repository.OwnerFromContext(ctx) {
+		if err := s.requireListRole(ctx, listID, models.ListOwner); err != nil {
+			return err
+		}
+	} else if _, err := s.repository.TodoLists.ListRole(ctx, listID); err != nil {
+		return err
+	}
+	return s.repository.TodoLists.RemoveListMember(ctx, listID, userID, now())
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"newFeatures/migration"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// listFixture is a SQLite backend with the users owner, editor, viewer and
+// stranger. The owner has shared the lists shared and other with the editor
+// and the viewer in their roles, and has a todo in shared; the editor has a
+// list private of their own.
+type listFixture struct {
+	s                               *TodoService
+	owner, editor, viewer, stranger context.Context
+	shared, other, private, ownTodo string
+	invitations                     chan *models.Invitation
+}
+
+func newListFixture(t *testing.T) *listFixture {
+	t.Helper()
+	t.Setenv("TOKEN_KEY", "secret")
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "todo.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migration.New(repository.SQLiteDB, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := repository.NewRepository(repository.SQLiteDB, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &listFixture{
+		s:           &TodoService{repository: repo, backend: repository.SQLiteDB, users: repo.AuthorizationApp},
+		invitations: make(chan *models.Invitation, 10),
+	}
+	send := sendInvitation
+	sendInvitation = func(invitation *models.Invitation) { f.invitations <- invitation }
+	t.Cleanup(func() { sendInvitation = send })
+
+	ctx := context.Background()
+	users := []*context.Context{&f.owner, &f.editor, &f.viewer, &f.stranger}
+	for i, name := range []string{"owner", "editor", "viewer", "stranger"} {
+		user := &models.User{Name: name, Email: name + "@example.com", Phone: name, Password: "secret", Role: models.RoleUser}
+		if err := repo.AuthorizationApp.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		*users[i] = WithCaller(ctx, i+1, string(models.RoleUser))
+	}
+
+	for _, list := range []*string{&f.shared, &f.other} {
+		if *list, err = f.s.CreateList(f.owner, &models.TodoList{Name: "list"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, member := range []struct {
+			ctx  context.Context
+			role models.ListRole
+		}{{f.editor, models.ListEditor}, {f.viewer, models.ListViewer}} {
+			if err := repo.TodoLists.SetListMember(ctx, *list, repository.OwnerFromContext(member.ctx), member.role, now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if f.private, err = f.s.CreateList(f.editor, &models.TodoList{Name: "private"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.ownTodo, err = f.s.CreateTodo(f.owner, &models.Todo{Title: "todo", ListID: f.shared}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// invite has the owner invite the user with email to the shared list and
+// returns the mailed token.
+func (f *listFixture) invite(t *testing.T, email string, role models.ListRole) string {
+	t.Helper()
+	if err := f.s.InviteToList(f.owner, f.shared, email, role); err != nil {
+		t.Fatalf("InviteToList() error = %v", err)
+	}
+	select {
+	case invitation := <-f.invitations:
+		return invitation.Token
+	case <-time.After(5 * time.Second):
+		t.Fatal("InviteToList() sent no invitation")
+		return ""
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(f *listFixture) error
+		err  error
+	}{
+		{
+			name: "viewer moves a todo",
+			do: func(f *listFixture) error {
+				_, err := f.s.MoveTodo(f.viewer, f.ownTodo, f.other)
+				return err
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "editor moves a todo",
+			do: func(f *listFixture) error {
+				_, err := f.s.MoveTodo(f.editor, f.ownTodo, f.other)
+				return err
+			},
+		},
+		{
+			name: "editor moves a todo into a list of their own",
+			do: func(f *listFixture) error {
+				_, err := f.s.MoveTodo(f.editor, f.ownTodo, f.private)
+				return err
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "owner moves a todo",
+			do: func(f *listFixture) error {
+				_, err := f.s.MoveTodo(f.owner, f.ownTodo, f.other)
+				return err
+			},
+		},
+		{
+			name: "stranger moves a todo",
+			do: func(f *listFixture) error {
+				_, err := f.s.MoveTodo(f.stranger, f.ownTodo, f.other)
+				return err
+			},
+			err: repository.ErrListNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newListFixture(t)
+			if err := tt.do(f); !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestListACL(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(f *listFixture) error
+		err  error
+	}{
+		{
+			name: "viewer invites",
+			do: func(f *listFixture) error {
+				return f.s.InviteToList(f.viewer, f.shared, "stranger@example.com", models.ListViewer)
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "editor invites",
+			do: func(f *listFixture) error {
+				return f.s.InviteToList(f.editor, f.shared, "stranger@example.com", models.ListViewer)
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "owner invites",
+			do: func(f *listFixture) error {
+				return f.s.InviteToList(f.owner, f.shared, "stranger@example.com", models.ListViewer)
+			},
+		},
+		{
+			name: "stranger invites",
+			do: func(f *listFixture) error {
+				return f.s.InviteToList(f.stranger, f.shared, "stranger@example.com", models.ListViewer)
+			},
+			err: repository.ErrListNotFound,
+		},
+		{
+			name: "viewer removes the editor",
+			do: func(f *listFixture) error {
+				return f.s.RemoveListMember(f.viewer, f.shared, repository.OwnerFromContext(f.editor))
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "editor removes the viewer",
+			do: func(f *listFixture) error {
+				return f.s.RemoveListMember(f.editor, f.shared, repository.OwnerFromContext(f.viewer))
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "viewer leaves",
+			do: func(f *listFixture) error {
+				return f.s.RemoveListMember(f.viewer, f.shared, repository.OwnerFromContext(f.viewer))
+			},
+		},
+		{
+			name: "owner removes the editor",
+			do: func(f *listFixture) error {
+				return f.s.RemoveListMember(f.owner, f.shared, repository.OwnerFromContext(f.editor))
+			},
+		},
+		{
+			name: "last owner leaves",
+			do: func(f *listFixture) error {
+				return f.s.RemoveListMember(f.owner, f.shared, repository.OwnerFromContext(f.owner))
+			},
+			err: repository.ErrLastListOwner,
+		},
+		{
+			name: "editor promotes the viewer",
+			do: func(f *listFixture) error {
+				return f.s.SetListMemberRole(f.editor, f.shared, repository.OwnerFromContext(f.viewer), models.ListOwner)
+			},
+			err: ErrListForbidden,
+		},
+		{
+			name: "owner promotes the viewer",
+			do: func(f *listFixture) error {
+				return f.s.SetListMemberRole(f.owner, f.shared, repository.OwnerFromContext(f.viewer), models.ListEditor)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newListFixture(t)
+			if err := tt.do(f); !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	tests := []struct {
+		name string
+		// accept returns the error of the accept under test.
+		accept func(t *testing.T, f *listFixture) error
+		err    error
+	}{
+		{
+			name: "invited user",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "stranger@example.com", models.ListEditor)
+				if _, err := f.s.AcceptInvitation(f.stranger, token); err != nil {
+					return err
+				}
+				role, err := f.s.repository.TodoLists.ListRole(f.stranger, f.shared)
+				if err == nil && role != models.ListEditor {
+					t.Errorf("role after accepting = %s, want %s", role, models.ListEditor)
+				}
+				return err
+			},
+		},
+		{
+			name: "another user",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "stranger@example.com", models.ListEditor)
+				_, err := f.s.AcceptInvitation(f.viewer, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "accepted before",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "stranger@example.com", models.ListViewer)
+				if _, err := f.s.AcceptInvitation(f.stranger, token); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.s.AcceptInvitation(f.stranger, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "revoked",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "stranger@example.com", models.ListViewer)
+				if err := f.s.RevokeInvitations(f.owner, f.shared, repository.OwnerFromContext(f.stranger)); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.s.AcceptInvitation(f.stranger, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "role changed since",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "viewer@example.com", models.ListOwner)
+				if err := f.s.SetListMemberRole(f.owner, f.shared, repository.OwnerFromContext(f.viewer), models.ListEditor); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.s.AcceptInvitation(f.viewer, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "removed since",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "viewer@example.com", models.ListEditor)
+				if err := f.s.RemoveListMember(f.owner, f.shared, repository.OwnerFromContext(f.viewer)); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.s.AcceptInvitation(f.viewer, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "newer invitation accepted",
+			accept: func(t *testing.T, f *listFixture) error {
+				older := f.invite(t, "stranger@example.com", models.ListOwner)
+				newer := f.invite(t, "stranger@example.com", models.ListViewer)
+				if _, err := f.s.AcceptInvitation(f.stranger, newer); err != nil {
+					t.Fatal(err)
+				}
+				_, err := f.s.AcceptInvitation(f.stranger, older)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "other backend",
+			accept: func(t *testing.T, f *listFixture) error {
+				token := f.invite(t, "stranger@example.com", models.ListViewer)
+				other := &TodoService{repository: f.s.repository, backend: repository.PostgresDB, users: f.s.users}
+				_, err := other.AcceptInvitation(f.stranger, token)
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+		{
+			name: "forged token",
+			accept: func(t *testing.T, f *listFixture) error {
+				_, err := f.s.AcceptInvitation(f.stranger, "forged.token.value")
+				return err
+			},
+			err: ErrInvalidInvitation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newListFixture(t)
+			if err := tt.accept(t, f); !errors.Is(err, tt.err) {
+				t.Errorf("AcceptInvitation() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
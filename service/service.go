@@ -2,72 +2,43 @@ package service
 
 import (
 	"context"
-	"errors"
 	"newFeatures/models"
 	"newFeatures/repository"
-
-	"github.com/gocql/gocql"
-	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type TodoPostgresService interface {
-	GetTodo(id int) (*models.Todo, error)
-	GetTodos(page, limit int64) ([]models.Todo, int, error)
-	CreateTodo(todo *models.Todo) (int, error)
-	UpdateTodo(todo *models.Todo) error
-	DeleteTodoByID(id int) (int, error)
-}
-type TodoMongoService interface {
-	GetTodo(id primitive.ObjectID) (*models.TodoMongo, error)
-	GetTodos(page, limit int64) ([]models.TodoMongo, int, error)
-	CreateTodo(todo *models.TodoMongo) (string, error)
-	UpdateTodo(todo *models.TodoMongo) error
-	DeleteTodoByID(id primitive.ObjectID) (string, error)
-}
-type TodoElasticService interface {
-	GetTodo(ctx context.Context, id string) (*models.TodoElastic, error)
-	GetTodos(ctx context.Context, page, limit int64) ([]models.TodoElastic, error)
-	CreateTodo(ctx context.Context, input *models.TodoElastic) (string, error)
-	UpdateTodo(ctx context.Context, todo *models.TodoElastic) (string, error)
-	DeleteTodoByID(ctx context.Context, id string) error
-	SearchTodos(ctx context.Context, query string, page, limit int64) ([]models.TodoElastic, error)
-}
-type TodoCassandraService interface {
-	CreateTodo(ctx context.Context, todo models.TodoCassandra) error
-	UpdateTodo(ctx context.Context, todo models.TodoCassandra) error
-	DeleteTodoByID(ctx context.Context, id gocql.UUID) error
-	GetTodos(ctx context.Context, page int, limit []byte) ([]models.TodoCassandra, []byte, error)
-	GetTodoByID(ctx context.Context, id gocql.UUID) (models.TodoCassandra, error)
-}
-type TodoMariaService interface {
-	CreateTodo(ctx context.Context, todo *models.TodoMaria) (int, error)
-	UpdateTodo(ctx context.Context, todo *models.TodoMaria) error
-	DeleteTodoByID(ctx context.Context, id int) error
-	GetTodos(ctx context.Context, page int64, limit int64) ([]models.TodoMaria, error)
-	GetTodoByID(ctx context.Context, id int) (models.TodoMaria, error)
-}
-type TodoClickHouseService interface {
-	CreateTodo(ctx context.Context, todo *models.TodoClickHouse) error
-	UpdateTodo(ctx context.Context, todo *models.TodoClickHouse) error
-	DeleteTodo(ctx context.Context, id uuid.UUID) error
-	GetTodos(ctx context.Context, page, limit int64) ([]models.TodoClickHouse, error)
-	GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoClickHouse, error)
-}
-
-type TodoCockroachService interface {
-	CreateTodo(ctx context.Context, todo *models.TodoCockroach) error
-	UpdateTodo(ctx context.Context, todo *models.TodoCockroach) error
-	DeleteTodo(ctx context.Context, id uuid.UUID) error
-	GetTodos(ctx context.Context, page, limit int) ([]models.TodoCockroach, error)
-	GetTodoByID(ctx context.Context, id uuid.UUID) (*models.TodoCockroach, error)
+type Todo interface {
+	GetTodo(ctx context.Context, id string) (*models.Todo, error)
+	GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, int64, error)
+	ListTodos(ctx context.Context, query models.TodoQuery, cursor string) (*models.Page[models.Todo], error)
+	CreateTodo(ctx context.Context, todo *models.Todo) (string, error)
+	UpdateTodo(ctx context.Context, todo *models.Todo) error
+	DeleteTodoByID(ctx context.Context, id string) ([]string, error)
+	ChildTodos(ctx context.Context, id string) ([]models.Todo, error)
+	ExpandChildren(ctx context.Context, todos []models.Todo) error
+	CompleteDescendants(ctx context.Context, id string) ([]string, error)
+	SearchTodos(ctx context.Context, query string, page, limit int64) (*models.TodoSearchResult, error)
+	SearchTodosAfter(ctx context.Context, query, after string, limit int64) (*models.TodoSearchResult, error)
+	SuggestTodos(ctx context.Context, prefix string, limit int64) ([]string, error)
+	BulkCreateTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error)
+	GetLists(ctx context.Context, archived bool, cursor string, limit int64) (*models.Page[models.TodoList], error)
+	GetList(ctx context.Context, id string) (*models.TodoList, error)
+	CreateList(ctx context.Context, list *models.TodoList) (string, error)
+	UpdateList(ctx context.Context, list *models.TodoList) error
+	DeleteList(ctx context.Context, id string) ([]string, error)
+	MoveTodo(ctx context.Context, todoID, listID string) ([]string, error)
+	ListMembers(ctx context.Context, listID string) ([]models.ListMember, error)
+	InviteToList(ctx context.Context, listID, email string, role models.ListRole) error
+	AcceptInvitation(ctx context.Context, token string) (*models.TodoList, error)
+	RevokeInvitations(ctx context.Context, listID, userID string) error
+	SetListMemberRole(ctx context.Context, listID, userID string, role models.ListRole) error
+	RemoveListMember(ctx context.Context, listID, userID string) error
 }
 
 type Authorization interface {
 	CreateUser(ctx context.Context, user *models.User) (*models.GenerateTokens, error)
 	AuthUser(ctx context.Context, user *models.User) (tokens *models.GenerateTokens, err error)
 	User(ctx context.Context, userID int) (*models.ResponseUser, error)
-	Users(ctx context.Context, page, limit int64) ([]models.ResponseUser, error)
+	Users(ctx context.Context, cursor string, limit int64) (*models.Page[models.ResponseUser], error)
 	UpdateUser(ctx context.Context, inputUser *models.ResponseUser) error
 	DeleteUser(ctx context.Context, userID int) error
 	RefreshToken(refreshToken string) (*models.GenerateTokens, error)
@@ -78,60 +49,40 @@ type Authorization interface {
 }
 
 type Service struct {
-	TodoPostgresService
-	TodoMongoService
-	TodoElasticService
-	TodoCassandraService
-	TodoMariaService
-	TodoClickHouseService
-	TodoCockroachService
 	Authorization
+	backends map[string]Todo
 }
 
-var serviceFactories = map[string]func(*repository.Repository) interface{}{
-	repository.PostgresDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoPostgresService: &PostgresService{repository: r},
-			Authorization:       &AuthorizationService{repository: r},
-		}
-	},
-	repository.MongoDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoMongoService: &MongoService{repository: r},
+// NewTodoService builds a Service over every enabled backend, keyed by its
+// CURRENT_DB name. Authorization is served by the first backend, in the given
+// order, that provides an AuthorizationApp, and the lists of every backend
+// are shared with its users.
+func NewTodoService(dbTypes []string, repos map[string]*repository.Repository) *Service {
+	s := &Service{backends: make(map[string]Todo, len(dbTypes))}
+	todos := make([]*TodoService, 0, len(dbTypes))
+	var users *repository.Repository
+	for _, dbType := range dbTypes {
+		repo := repos[dbType]
+		todo := &TodoService{repository: repo, backend: dbType}
+		s.backends[dbType] = todo
+		todos = append(todos, todo)
+		if users == nil && repo.AuthorizationApp != nil {
+			users = repo
 		}
-	},
-	repository.ElasticSearchDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoElasticService: &ElasticService{repository: r},
-		}
-	},
-	repository.CassandraDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoCassandraService: &CassandraService{repository: r},
-		}
-	},
-	repository.MariaDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoMariaService: &MariaService{repository: r},
-		}
-	},
-	repository.ClickHouseDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoClickHouseService: &ClickHouseService{repository: r},
-		}
-	},
-	repository.CockroachDB: func(r *repository.Repository) interface{} {
-		return &Service{
-			TodoCockroachService: &CockroachService{repository: r},
+	}
+	if users == nil && len(dbTypes) > 0 {
+		users = repos[dbTypes[0]]
+	}
+	if users != nil {
+		s.Authorization = &AuthorizationService{repository: users}
+		for _, todo := range todos {
+			todo.users = users.AuthorizationApp
 		}
-	},
-}
-
-func NewTodoService(dbType string, db *repository.Repository) (*Service, error) {
-	serviceFactory, ok := serviceFactories[dbType]
-	if !ok {
-		return nil, errors.New("unsupported database type")
 	}
+	return s
+}
 
-	return serviceFactory(db).(*Service), nil
+// Backend returns the todo service of an enabled backend, or nil.
+func (s *Service) Backend(dbType string) Todo {
+	return s.backends[dbType]
 }
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"os"
+	"strconv"
+)
+
+// DefaultMaxTodoDepth is how many levels of subtasks a todo may have unless
+// TODO_MAX_DEPTH says otherwise.
+const DefaultMaxTodoDepth = 3
+
+var (
+	ErrSubtasksNotSupported = errors.New("subtasks are not supported by the configured database")
+	ErrParentNotFound       = errors.New("parent todo not found")
+	ErrTodoTooDeep          = errors.New("subtasks are nested deeper than allowed")
+	ErrParentListMismatch   = errors.New("a subtask must be in the list of its parent")
+	ErrSubtaskMove          = errors.New("subtasks move with their parent")
+)
+
+// maxTodoDepth reads TODO_MAX_DEPTH; unset or malformed values, and values
+// below 1, keep DefaultMaxTodoDepth.
+func maxTodoDepth() int {
+	depth, err := strconv.Atoi(os.Getenv("TODO_MAX_DEPTH"))
+	if err != nil || depth < 1 {
+		return DefaultMaxTodoDepth
+	}
+	return depth
+}
+
+// checkTodoParent checks that a todo about to be created may be a subtask
+// of the todo it names as parent, and puts it into the list and under the
+// owner of the parent. The parent must be readable within ctx and the todo
+// must not be nested deeper than maxTodoDepth.
+func (s *TodoService) checkTodoParent(ctx context.Context, todo *models.Todo) error {
+	if todo.ParentID == "" {
+		return nil
+	}
+	if s.repository.TodoTree == nil {
+		return ErrSubtasksNotSupported
+	}
+	parent, err := s.repository.TodoStore.GetTodoByID(ctx, todo.ParentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTodoNotFound) || errors.Is(err, repository.ErrInvalidTodoID) {
+			return ErrParentNotFound
+		}
+		return err
+	}
+	switch {
+	case todo.ListID == "":
+		todo.ListID = parent.ListID
+	case todo.ListID != parent.ListID:
+		return ErrParentListMismatch
+	}
+	todo.OwnerID = parent.OwnerID
+
+	max := maxTodoDepth()
+	for depth := 1; parent.ParentID != ""; depth++ {
+		if depth >= max {
+			return ErrTodoTooDeep
+		}
+		if parent, err = s.repository.TodoStore.GetTodoByID(ctx, parent.ParentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpandChildren fills in the subtasks of todos and theirs in turn, down to
+// the maximum depth, with the progress of every todo that has subtasks. It
+// reads one level of the trees per query. The todos whose subtasks were read
+// get non-nil Children, empty if they have none; those below the maximum
+// depth keep nil.
+func (s *TodoService) ExpandChildren(ctx context.Context, todos []models.Todo) error {
+	if s.repository.TodoTree == nil {
+		return ErrSubtasksNotSupported
+	}
+	level := make([]*models.Todo, len(todos))
+	for i := range todos {
+		level[i] = &todos[i]
+	}
+	for depth := 0; depth < maxTodoDepth() && len(level) > 0; depth++ {
+		ids := make([]string, len(level))
+		for i, todo := range level {
+			ids[i] = todo.ID
+		}
+		children, err := s.repository.TodoTree.ChildTodos(ctx, ids)
+		if err != nil {
+			return err
+		}
+		byParent := make(map[string][]models.Todo)
+		for _, child := range children {
+			byParent[child.ParentID] = append(byParent[child.ParentID], child)
+		}
+
+		next := []*models.Todo{}
+		for _, todo := range level {
+			todo.Children = byParent[todo.ID]
+			if len(todo.Children) == 0 {
+				todo.Children = []models.Todo{}
+				continue
+			}
+			// Each parent gets its own copy, as a todo may be listed along
+			// with its parent.
+			todo.Children = append([]models.Todo(nil), todo.Children...)
+			todo.Progress = models.ProgressOf(todo.Children)
+			for i := range todo.Children {
+				next = append(next, &todo.Children[i])
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// ChildTodos returns the subtasks of the todo with id, by id.
+func (s *TodoService) ChildTodos(ctx context.Context, id string) ([]models.Todo, error) {
+	if s.repository.TodoTree == nil {
+		return nil, ErrSubtasksNotSupported
+	}
+	return s.repository.TodoTree.ChildTodos(ctx, []string{id})
+}
+
+// descendants returns the subtasks below the todo with id, level by level,
+// or none on the backends without subtasks.
+func (s *TodoService) descendants(ctx context.Context, id string) ([]models.Todo, error) {
+	if s.repository.TodoTree == nil {
+		return nil, nil
+	}
+	all := []models.Todo{}
+	seen := map[string]bool{id: true}
+	for level := []string{id}; len(level) > 0; {
+		children, err := s.repository.TodoTree.ChildTodos(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+		next := []string{}
+		for _, child := range children {
+			// A tree has no cycles, but the index of a backend may have
+			// been written to directly.
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+			all = append(all, child)
+			next = append(next, child.ID)
+		}
+		level = next
+	}
+	return all, nil
+}
+
+// CompleteDescendants marks every open subtask below the todo with id done
+// and returns their ids. The owner of ctx must be allowed to update them,
+// which it is when it may update the todo.
+func (s *TodoService) CompleteDescendants(ctx context.Context, id string) ([]string, error) {
+	if s.repository.TodoTree == nil {
+		return nil, ErrSubtasksNotSupported
+	}
+	subtasks, err := s.descendants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	completed := []string{}
+	for _, subtask := range subtasks {
+		if subtask.Done {
+			continue
+		}
+		subtask.Done = true
+		if err := s.UpdateTodo(ctx, &subtask); err != nil {
+			return completed, err
+		}
+		completed = append(completed, subtask.ID)
+	}
+	return completed, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"testing"
+)
+
+func newMemoryTodoService(t *testing.T) *TodoService {
+	t.Helper()
+	repo, err := repository.NewRepository(repository.MemoryDB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TodoService{repository: repo, backend: repository.MemoryDB}
+}
+
+func TestCreateTodoDepthLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth string
+		// depth is how many todos are above the new one.
+		depth int
+		err   error
+	}{
+		{name: "top level", depth: 0},
+		{name: "default first level", depth: 1},
+		{name: "default deepest level", depth: DefaultMaxTodoDepth},
+		{name: "default too deep", depth: DefaultMaxTodoDepth + 1, err: ErrTodoTooDeep},
+		{name: "one level", maxDepth: "1", depth: 1},
+		{name: "one level too deep", maxDepth: "1", depth: 2, err: ErrTodoTooDeep},
+		{name: "five levels", maxDepth: "5", depth: 5},
+		{name: "five levels too deep", maxDepth: "5", depth: 6, err: ErrTodoTooDeep},
+		{name: "zero keeps the default", maxDepth: "0", depth: DefaultMaxTodoDepth},
+		{name: "malformed keeps the default", maxDepth: "deep", depth: DefaultMaxTodoDepth + 1, err: ErrTodoTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TODO_MAX_DEPTH", tt.maxDepth)
+			s := newMemoryTodoService(t)
+			ctx := repository.WithOwner(context.Background(), "1")
+
+			// The chain above the new todo is written to the repository
+			// directly, as it may be deeper than the service allows.
+			var parentID string
+			for i := 0; i < tt.depth; i++ {
+				parent := models.Todo{OwnerID: "1", Title: "parent", ParentID: parentID}
+				id, err := s.repository.TodoStore.CreateTodo(ctx, &parent)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parentID = id
+			}
+
+			_, err := s.CreateTodo(ctx, &models.Todo{Title: "subtask", ParentID: parentID})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("CreateTodo() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCreateTodoParent(t *testing.T) {
+	s := newMemoryTodoService(t)
+	ctx := repository.WithOwner(context.Background(), "1")
+	parent := models.Todo{Title: "parent"}
+	parentID, err := s.CreateTodo(ctx, &parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		parentID string
+		err      error
+	}{
+		{name: "own parent", ctx: ctx, parentID: parentID},
+		{name: "missing parent", ctx: ctx, parentID: "999", err: ErrParentNotFound},
+		{name: "malformed parent", ctx: ctx, parentID: "first", err: ErrParentNotFound},
+		{name: "parent of another owner", ctx: repository.WithOwner(context.Background(), "2"), parentID: parentID, err: ErrParentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := models.Todo{Title: "subtask", ParentID: tt.parentID}
+			_, err := s.CreateTodo(tt.ctx, &todo)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("CreateTodo() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && todo.OwnerID != parent.OwnerID {
+				t.Errorf("CreateTodo() owner = %q, want the owner of the parent %q", todo.OwnerID, parent.OwnerID)
+			}
+		})
+	}
+}
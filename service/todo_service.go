@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"newFeatures/models"
+	"newFeatures/repository"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxTitleLength is the length of the title column of the SQL backends.
+	maxTitleLength = 225
+	// maxDescriptionLength keeps descriptions within what every backend
+	// stores comfortably, MariaDB's TEXT being the smallest.
+	maxDescriptionLength = 10000
+)
+
+// TodoService acts within the owner scope of the context of each call, see
+// WithCaller: todos outside it are reported as not found. On the backends
+// with lists the scope takes in the lists shared with the owner.
+type TodoService struct {
+	repository *repository.Repository
+	// backend is the CURRENT_DB name, which scopes the cursors handed out.
+	backend string
+	// users are the users lists are shared with, nil when no backend
+	// stores users.
+	users repository.AuthorizationApp
+}
+
+var (
+	ErrInvalidPagination  = errors.New("invalid page or limit value")
+	ErrEmptyTitle         = errors.New("todo title is empty")
+	ErrSearchNotSupported = errors.New("search is not supported by the configured database")
+	ErrBulkNotSupported   = errors.New("bulk ingest is not supported by the configured database")
+	ErrInvalidSort        = errors.New("invalid sort, use id, -id, title, -title, created_at or -created_at")
+	ErrInvalidFilter      = errors.New("invalid filter")
+	ErrInvalidPriority    = fmt.Errorf("todo priority must be between %d and %d", models.PriorityNone, models.PriorityHigh)
+	ErrLongDescription    = fmt.Errorf("todo description is longer than %d bytes", maxDescriptionLength)
+)
+
+// WithCaller scopes the todo operations of ctx to the todos of the user with
+// userID, or to the todos of every user when role is RoleAdmin. The todos
+// created with it belong to the user either way.
+func WithCaller(ctx context.Context, userID int, role string) context.Context {
+	ctx = repository.WithOwner(ctx, strconv.Itoa(userID))
+	if role == string(models.RoleAdmin) {
+		ctx = repository.WithAllOwners(ctx)
+	}
+	return ctx
+}
+
+// validateTodo checks the fields clients set.
+func validateTodo(todo *models.Todo) error {
+	switch {
+	case todo.Title == "":
+		return ErrEmptyTitle
+	case todo.Priority < models.PriorityNone || todo.Priority > models.PriorityHigh:
+		return ErrInvalidPriority
+	case len(todo.Description) > maxDescriptionLength:
+		return ErrLongDescription
+	}
+	return nil
+}
+
+// now returns the time todos are stamped with, in UTC and rounded down to
+// milliseconds, the finest precision all backends store.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// stampNew sets the timestamps of a todo about to be created and normalizes
+// its due date like them.
+func stampNew(todo *models.Todo) {
+	todo.CreatedAt = now()
+	todo.UpdatedAt = todo.CreatedAt
+	normalizeDue(todo)
+}
+
+func normalizeDue(todo *models.Todo) {
+	if todo.DueAt != nil {
+		due := todo.DueAt.UTC().Truncate(time.Millisecond)
+		todo.DueAt = &due
+	}
+}
+
+func (s *TodoService) GetTodo(ctx context.Context, id string) (*models.Todo, error) {
+	todo, err := s.repository.TodoStore.GetTodoByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// GetTodos returns the requested page together with the total number of pages.
+func (s *TodoService) GetTodos(ctx context.Context, page, limit int64) ([]models.Todo, int64, error) {
+	if page < 1 || limit < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+	todos, err := s.repository.TodoStore.GetTodos(ctx, page, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get todos: %w", err)
+	}
+	count, err := s.repository.TodoStore.CountTodos(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count todos: %w", err)
+	}
+	return todos, (count + limit - 1) / limit, nil
+}
+
+// todoSorts whitelists the sort parameter of the todo lists.
+var todoSorts = map[string]models.TodoSort{
+	"id":     {Field: models.SortByID},
+	"-id":    {Field: models.SortByID, Desc: true},
+	"title":  {Field: models.SortByTitle},
+	"-title": {Field: models.SortByTitle, Desc: true},
+
+	"created_at":  {Field: models.SortByCreatedAt},
+	"-created_at": {Field: models.SortByCreatedAt, Desc: true},
+}
+
+// ParseTodoSort parses a sort parameter: a field name, descending when
+// prefixed with a minus. An empty parameter keeps the backend's order.
+func ParseTodoSort(sort string) (models.TodoSort, error) {
+	if sort == "" {
+		return models.TodoSort{}, nil
+	}
+	parsed, ok := todoSorts[sort]
+	if !ok {
+		return models.TodoSort{}, ErrInvalidSort
+	}
+	return parsed, nil
+}
+
+// ListTodos returns the page of todos that follows cursor, the first page for
+// an empty cursor. query.After is taken from the cursor, which only works
+// with the sort order it was issued for. One todo more than asked for is read
+// to learn whether a next page exists.
+func (s *TodoService) ListTodos(ctx context.Context, query models.TodoQuery, cursor string) (*models.Page[models.Todo], error) {
+	if query.Limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	if len(query.Title) > maxTitleLength || emptyRange(query.Created) || emptyRange(query.Due) {
+		return nil, ErrInvalidFilter
+	}
+	if query.ListID != "" && s.repository.TodoLists == nil {
+		return nil, ErrListsNotSupported
+	}
+	scope := s.listScope(query.Sort)
+	query.After = nil
+	if cursor != "" {
+		query.After = &models.TodoKey{}
+		if err := decodeCursor(scope, cursor, query.After); err != nil {
+			return nil, err
+		}
+	}
+	limit := query.Limit
+	query.Limit++
+	todos, err := s.repository.TodoStore.ListTodos(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todos: %w", err)
+	}
+
+	page := &models.Page[models.Todo]{Items: todos}
+	if int64(len(todos)) > limit {
+		page.Items = todos[:limit]
+		last := todos[limit-1]
+		key := models.TodoKey{ID: last.ID}
+		switch query.Sort.Field {
+		case models.SortByTitle:
+			key.Title = last.Title
+		case models.SortByCreatedAt:
+			key.CreatedAt = &last.CreatedAt
+		}
+		page.NextCursor = encodeCursor(scope, key)
+	}
+	return page, nil
+}
+
+// emptyRange reports a range whose end is not after its start.
+func emptyRange(bounds models.TimeRange) bool {
+	return bounds.From != nil && bounds.To != nil && !bounds.To.After(*bounds.From)
+}
+
+// listScope ties cursors to the backend and the sort order of their list.
+func (s *TodoService) listScope(sort models.TodoSort) string {
+	scope := "todos:" + s.backend + ":" + string(sort.Field)
+	if sort.Desc {
+		scope += ":desc"
+	}
+	return scope
+}
+
+func (s *TodoService) CreateTodo(ctx context.Context, todo *models.Todo) (string, error) {
+	if err := validateTodo(todo); err != nil {
+		return "", err
+	}
+	stampNew(todo)
+	todo.OwnerID = repository.OwnerFromContext(ctx)
+	todo.Children, todo.Progress = nil, nil
+	if err := s.checkTodoParent(ctx, todo); err != nil {
+		return "", err
+	}
+	if err := s.checkTodoList(ctx, todo); err != nil {
+		return "", err
+	}
+	id, err := s.repository.TodoStore.CreateTodo(ctx, todo)
+	if err != nil {
+		return "", fmt.Errorf("failed to create todo: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateTodo replaces the fields clients set. The backends keep the owner,
+// the list, the parent and created_at; MoveTodo changes the list.
+func (s *TodoService) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	if err := validateTodo(todo); err != nil {
+		return err
+	}
+	todo.OwnerID = ""
+	todo.ListID = ""
+	todo.ParentID = ""
+	todo.Children, todo.Progress = nil, nil
+	todo.CreatedAt = time.Time{}
+	todo.UpdatedAt = now()
+	normalizeDue(todo)
+	err := s.repository.TodoStore.UpdateTodo(ctx, todo)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteTodoByID deletes a todo together with its subtasks, the deepest
+// first, and returns the ids of the subtasks.
+func (s *TodoService) DeleteTodoByID(ctx context.Context, id string) ([]string, error) {
+	subtasks, err := s.descendants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deleted := []string{}
+	for i := len(subtasks) - 1; i >= 0; i-- {
+		err := s.repository.TodoStore.DeleteTodoByID(ctx, subtasks[i].ID)
+		if err != nil && !errors.Is(err, repository.ErrTodoNotFound) {
+			return deleted, err
+		}
+		deleted = append(deleted, subtasks[i].ID)
+	}
+	if err := s.repository.TodoStore.DeleteTodoByID(ctx, id); err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
+
+func (s *TodoService) SearchTodos(ctx context.Context, query string, page, limit int64) (*models.TodoSearchResult, error) {
+	if s.repository.TodoSearch == nil {
+		return nil, ErrSearchNotSupported
+	}
+	if page < 1 || limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	result, err := s.repository.TodoSearch.SearchTodos(ctx, query, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.signSearchCursors(result)
+	return result, nil
+}
+
+// SearchTodosAfter returns the todos after the cursor. Unlike SearchTodos it
+// can page through any number of results.
+func (s *TodoService) SearchTodosAfter(ctx context.Context, query, after string, limit int64) (*models.TodoSearchResult, error) {
+	if s.repository.TodoSearch == nil {
+		return nil, ErrSearchNotSupported
+	}
+	if limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	if after != "" {
+		if err := decodeCursor("search:"+s.backend, after, &after); err != nil {
+			return nil, err
+		}
+	}
+	result, err := s.repository.TodoSearch.SearchTodosAfter(ctx, query, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.signSearchCursors(result)
+	return result, nil
+}
+
+// signSearchCursors replaces the cursors of the backend, which expose its
+// sort values, with signed tokens.
+func (s *TodoService) signSearchCursors(result *models.TodoSearchResult) {
+	for i := range result.Hits {
+		result.Hits[i].Cursor = encodeCursor("search:"+s.backend, result.Hits[i].Cursor)
+	}
+}
+
+func (s *TodoService) SuggestTodos(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	if s.repository.TodoSearch == nil {
+		return nil, ErrSearchNotSupported
+	}
+	if limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+	if prefix == "" {
+		return []string{}, nil
+	}
+	return s.repository.TodoSearch.SuggestTodos(ctx, prefix, limit)
+}
+
+// BulkCreateTodos stores todos in bulk. Invalid todos, such as todos without
+// a title, are reported as failed items and not sent to the backend.
+func (s *TodoService) BulkCreateTodos(ctx context.Context, todos []models.Todo, refresh string) ([]models.BulkTodoResult, error) {
+	if s.repository.TodoBulk == nil {
+		return nil, ErrBulkNotSupported
+	}
+
+	valid := make([]models.Todo, 0, len(todos))
+	positions := make([]int, 0, len(todos))
+	results := make([]models.BulkTodoResult, len(todos))
+	for i, todo := range todos {
+		if err := validateTodo(&todo); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		stampNew(&todo)
+		todo.OwnerID = repository.OwnerFromContext(ctx)
+		if err := s.checkTodoParent(ctx, &todo); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err := s.checkTodoList(ctx, &todo); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, todo)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	stored, err := s.repository.TodoBulk.BulkCreateTodos(ctx, valid, refresh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create todos: %w", err)
+	}
+	for i, result := range stored {
+		results[positions[i]] = result
+	}
+	return results, nil
+}